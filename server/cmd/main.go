@@ -1,15 +1,37 @@
 package main
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/labstack/echo"
+	"github.com/takaryo1010/OneTimeChat/server/config"
 	"github.com/takaryo1010/OneTimeChat/server/controller"
+	"github.com/takaryo1010/OneTimeChat/server/logging"
 	"github.com/takaryo1010/OneTimeChat/server/periodicTask"
 	"github.com/takaryo1010/OneTimeChat/server/router"
+	"github.com/takaryo1010/OneTimeChat/server/tlsserver"
 	"github.com/takaryo1010/OneTimeChat/server/usecase"
 )
 
 func main() {
+	// 設定の読み込み(設定ファイル < 環境変数 < コマンドライン引数)
+	store, err := config.NewStore(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	cfg := store.Current()
 
 	// Usecase と Controller の初期化
 	roomUsecase := usecase.NewRoomUsecase()
@@ -17,15 +39,74 @@ func main() {
 		RoomUsecase: roomUsecase,
 	}
 
+	// ルーターの設定
+	e := router.NewRouter(mainController, cfg)
+
 	// 定期タスクの開始
-	go periodicTask.PeriodicTask(roomUsecase.RoomManager)
+	go periodicTask.PeriodicTask(roomUsecase.RoomManager, func() time.Duration {
+		return store.Current().SweepInterval.Duration
+	})
 
-	// ルーターの設定
-	e := router.NewRouter(mainController)
+	// SIGHUP で設定を再読み込みする
+	go reloadOnSignal(store, mainController)
 
 	// サーバーを起動
-	log.Println("Server started at http://localhost:8080")
-	if err := e.Start(":8080"); err != nil {
-		log.Fatalf("Error starting the server: %v", err)
+	if cfg.TLS.Enabled() {
+		if err := startTLS(e, cfg); err != nil {
+			logging.Fatal("error starting the server", "error", err)
+		}
+		return
+	}
+	slog.Info("server started", "addr", cfg.ListenAddr)
+	if err := e.Start(cfg.ListenAddr); err != nil {
+		logging.Fatal("error starting the server", "error", err)
+	}
+}
+
+// startTLS は listen_addr でHTTPSを提供する
+// 証明書ファイルの変更を監視して読み直し、redirect_addr が設定されていればHTTPからのリダイレクトも待ち受ける
+func startTLS(e *echo.Echo, cfg *config.Config) error {
+	reloader, err := tlsserver.NewCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	if err != nil {
+		return err
+	}
+	go reloader.Watch(tlsserver.DefaultPollInterval)
+
+	if cfg.TLS.RedirectAddr != "" {
+		redirect := &http.Server{
+			Addr:              cfg.TLS.RedirectAddr,
+			Handler:           tlsserver.RedirectHandler(cfg.ListenAddr),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			slog.Info("HTTP to HTTPS redirect started", "addr", cfg.TLS.RedirectAddr)
+			if err := redirect.ListenAndServe(); err != nil {
+				logging.Fatal("error starting the redirect listener", "error", err)
+			}
+		}()
+	}
+
+	e.TLSServer.Addr = cfg.ListenAddr
+	e.TLSServer.TLSConfig = tlsserver.Config(reloader, cfg.TLS.MinTLSVersion())
+	slog.Info("server started", "addr", cfg.ListenAddr, "tls", true, "min_version", cfg.TLS.MinVersion, "not_after", reloader.NotAfter())
+	return e.StartServer(e.TLSServer)
+}
+
+// reloadOnSignal は SIGHUP を受け取るたびに設定を読み直し、再読み込みできる項目を反映する
+// 設定に誤りがあれば現在の設定のまま動き続ける
+func reloadOnSignal(store *config.Store, mc *controller.MainController) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	for range sig {
+		cfg, ignored, err := store.Reload()
+		if err != nil {
+			slog.Error("config reload failed, keeping the current configuration", "error", err)
+			continue
+		}
+		router.Apply(mc, cfg)
+		if len(ignored) > 0 {
+			slog.Warn("some settings need a restart and were not reloaded", "fields", ignored)
+		}
+		slog.Info("configuration reloaded")
 	}
 }
@@ -0,0 +1,435 @@
+package config
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config はサーバーの設定
+// 設定ファイル(YAML/TOML) < 環境変数(.env を含む) < コマンドライン引数 の順に上書きする
+type Config struct {
+	ListenAddr string      `yaml:"listen_addr" toml:"listen_addr"` // 待ち受けるアドレス
+	TLS        TLSSettings `yaml:"tls" toml:"tls"`                 // 設定すると listen_addr で HTTPS を提供する
+
+	ClientURL      string   `yaml:"client_url" toml:"client_url"`           // フロントエンドのURL
+	AllowedOrigins []string `yaml:"allowed_origins" toml:"allowed_origins"` // 許可するオリジン(空なら client_url のみ)
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"` // X-Forwarded-For を信用するプロキシ(CIDR、空なら信用しない)
+
+	AdminToken            string   `yaml:"admin_token" toml:"admin_token"`                         // 運用者向けAPIのトークン(空なら無効)
+	MetricsToken          string   `yaml:"metrics_token" toml:"metrics_token"`                     // /metrics のトークン(空なら認証なし)
+	JoinCallbackAllowlist []string `yaml:"join_callback_allowlist" toml:"join_callback_allowlist"` // 参加ポリシー http_callback で問い合わせてよいURL
+
+	Cookie CookieSettings `yaml:"cookie" toml:"cookie"`
+	Log    LogSettings    `yaml:"log" toml:"log"`
+	Rooms  RoomLimits     `yaml:"rooms" toml:"rooms"`
+
+	SweepInterval Duration `yaml:"sweep_interval" toml:"sweep_interval"` // 期限切れの部屋とセッションを削除する間隔
+}
+
+// TLSSettings はHTTPSで提供するための設定
+// 証明書と鍵のファイルが書き換えられると自動で読み直す
+type TLSSettings struct {
+	CertFile     string `yaml:"cert_file" toml:"cert_file"`         // 証明書(中間証明書を含むPEM)
+	KeyFile      string `yaml:"key_file" toml:"key_file"`           // 秘密鍵(PEM)
+	MinVersion   string `yaml:"min_version" toml:"min_version"`     // 受け付ける最低のTLSバージョン (1.2, 1.3)
+	RedirectAddr string `yaml:"redirect_addr" toml:"redirect_addr"` // HTTPをHTTPSにリダイレクトするアドレス(空なら無効)
+}
+
+// CookieSettings は発行するクッキーの属性
+type CookieSettings struct {
+	HTTPOnly bool   `yaml:"http_only" toml:"http_only"`
+	Secure   bool   `yaml:"secure" toml:"secure"`
+	SameSite string `yaml:"same_site" toml:"same_site"` // lax, strict, none
+}
+
+// LogSettings はログ出力の設定
+type LogSettings struct {
+	Level   string `yaml:"level" toml:"level"`     // debug, info, warn, error
+	Format  string `yaml:"format" toml:"format"`   // text, json
+	Redact  bool   `yaml:"redact" toml:"redact"`   // セッションIDとメッセージ本文を伏せ字にする
+	Privacy bool   `yaml:"privacy" toml:"privacy"` // 参加者の名前とIPアドレスを出力しない
+}
+
+// RoomLimits は部屋に関するサーバー全体の上限
+type RoomLimits struct {
+	MinTTL          Duration `yaml:"min_ttl" toml:"min_ttl"`                   // 作成時に指定できる有効期限の下限
+	MaxTTL          Duration `yaml:"max_ttl" toml:"max_ttl"`                   // 作成時に指定できる有効期限の上限
+	MaxParticipants int      `yaml:"max_participants" toml:"max_participants"` // 1部屋あたりの参加者数の上限
+}
+
+// Duration は "5m" や "168h" のように書ける期間
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText は time.ParseDuration の書式の文字列を読む
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// MarshalText は time.Duration.String の書式で書き出す
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
+
+// Default は設定の既定値を返す
+func Default() *Config {
+	return &Config{
+		ListenAddr: ":8080",
+		TLS: TLSSettings{
+			MinVersion: "1.2",
+		},
+		ClientURL: "http://localhost:3000",
+		Cookie: CookieSettings{
+			HTTPOnly: true,
+			Secure:   true,
+			SameSite: "lax",
+		},
+		Log: LogSettings{
+			Level:  "info",
+			Format: "text",
+			Redact: true,
+		},
+		Rooms: RoomLimits{
+			MaxTTL:          Duration{7 * 24 * time.Hour},
+			MaxParticipants: 100,
+		},
+		SweepInterval: Duration{5 * time.Minute},
+	}
+}
+
+// option は環境変数・コマンドライン引数から設定できる項目
+// flag が空の項目は環境変数からのみ設定できる(トークンなどプロセス一覧に出したくないもの)
+type option struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var options = []option{
+	{"LISTEN_ADDR", "listen", "address to listen on", setString(func(c *Config) *string { return &c.ListenAddr })},
+	{"TLS_CERT_FILE", "tls-cert", "path to the TLS certificate (PEM)", setString(func(c *Config) *string { return &c.TLS.CertFile })},
+	{"TLS_KEY_FILE", "tls-key", "path to the TLS private key (PEM)", setString(func(c *Config) *string { return &c.TLS.KeyFile })},
+	{"TLS_MIN_VERSION", "tls-min-version", "minimum TLS version (1.2, 1.3)", setString(func(c *Config) *string { return &c.TLS.MinVersion })},
+	{"TLS_REDIRECT_ADDR", "tls-redirect-addr", "address of a listener that redirects HTTP to HTTPS", setString(func(c *Config) *string { return &c.TLS.RedirectAddr })},
+	{"CLIENT_URL", "client-url", "URL of the frontend", setString(func(c *Config) *string { return &c.ClientURL })},
+	{"ALLOWED_ORIGINS", "origins", "comma-separated allowed origins", setList(func(c *Config) *[]string { return &c.AllowedOrigins })},
+	{"TRUSTED_PROXIES", "trusted-proxies", "comma-separated CIDRs of reverse proxies whose X-Forwarded-For is trusted", setList(func(c *Config) *[]string { return &c.TrustedProxies })},
+	{"ADMIN_TOKEN", "", "", setString(func(c *Config) *string { return &c.AdminToken })},
+	{"METRICS_TOKEN", "", "", setString(func(c *Config) *string { return &c.MetricsToken })},
+	{"JOIN_CALLBACK_ALLOWLIST", "join-callback-allowlist", "comma-separated URL prefixes for http_callback join policies", setList(func(c *Config) *[]string { return &c.JoinCallbackAllowlist })},
+	{"COOKIE_HTTPONLY", "", "", setBool(func(c *Config) *bool { return &c.Cookie.HTTPOnly })},
+	{"COOKIE_SECURE", "", "", setBool(func(c *Config) *bool { return &c.Cookie.Secure })},
+	{"COOKIE_SAMESITE", "", "", setString(func(c *Config) *string { return &c.Cookie.SameSite })},
+	{"LOG_LEVEL", "log-level", "log level (debug, info, warn, error)", setString(func(c *Config) *string { return &c.Log.Level })},
+	{"LOG_FORMAT", "log-format", "log format (text, json)", setString(func(c *Config) *string { return &c.Log.Format })},
+	{"LOG_REDACT", "log-redact", "redact session IDs and message contents", setBool(func(c *Config) *bool { return &c.Log.Redact })},
+	{"LOG_PRIVACY", "log-privacy", "omit participant names and IP addresses", setBool(func(c *Config) *bool { return &c.Log.Privacy })},
+	{"ROOM_MIN_TTL", "room-min-ttl", "shortest lifetime a room may be created with", setDuration(func(c *Config) *Duration { return &c.Rooms.MinTTL })},
+	{"ROOM_MAX_TTL", "room-max-ttl", "longest lifetime a room may be created with", setDuration(func(c *Config) *Duration { return &c.Rooms.MaxTTL })},
+	{"MAX_PARTICIPANTS", "max-participants", "maximum participants per room", setInt(func(c *Config) *int { return &c.Rooms.MaxParticipants })},
+	{"SWEEP_INTERVAL", "sweep-interval", "interval between expired room sweeps", setDuration(func(c *Config) *Duration { return &c.SweepInterval })},
+}
+
+func setString(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setList(field func(*Config) *[]string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		var list []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+func setBool(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be true or false: %q", value)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func setInt(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be an integer: %q", value)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func setDuration(field func(*Config) *Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		return field(c).UnmarshalText([]byte(value))
+	}
+}
+
+// Load はコマンドライン引数 args (プログラム名を除く)、環境変数、設定ファイルから設定を読み込んで検証する
+// 設定ファイルは -config 引数か環境変数 CONFIG_FILE で指定する(なければ使わない)
+// カレントディレクトリの .env は、あれば環境変数として読む(実際の環境変数が優先)
+func Load(args []string) (*Config, error) {
+	flagValues := map[string]string{}
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	configPath := flags.String("config", "", "path to a YAML or TOML config file")
+	for _, opt := range options {
+		if opt.flag == "" {
+			continue
+		}
+		name := opt.flag
+		flags.Func(name, opt.usage, func(value string) error {
+			flagValues[name] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	env, err := readEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	c := Default()
+	path := *configPath
+	if path == "" {
+		path = env("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, opt := range options {
+		if value := env(opt.env); value != "" {
+			if err := opt.set(c, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", opt.env, err))
+			}
+		}
+	}
+	for _, opt := range options {
+		if value, ok := flagValues[opt.flag]; ok && opt.flag != "" {
+			if err := opt.set(c, value); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", opt.flag, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readEnv は環境変数を引く関数を返す
+// .env の値は実際の環境変数が設定されていない場合に使う
+// 再読み込みのたびに .env を読み直すので、.env の変更も反映される
+func readEnv() (func(string) string, error) {
+	dotenv, err := godotenv.Read()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf(".env: %w", err)
+	}
+	return func(key string) string {
+		if v, ok := os.LookupEnv(key); ok {
+			return v
+		}
+		return dotenv[key]
+	}, nil
+}
+
+// loadFile は拡張子から形式を判断して設定ファイルを読む
+// 知らない項目があれば書き間違いとしてエラーにする
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("config file %s: unknown field %q", path, undecoded[0].String())
+		}
+	default:
+		return fmt.Errorf("config file %s: unsupported format %q (use .yaml, .yml or .toml)", path, ext)
+	}
+	return nil
+}
+
+// Validate は設定の値を確認し、問題のある項目をすべてまとめて返す
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		fail("listen_addr", "must be host:port, got %q", c.ListenAddr)
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		fail("tls", "set both cert_file and key_file, or neither")
+	}
+	if _, ok := tlsVersions[c.TLS.MinVersion]; !ok {
+		fail("tls.min_version", "must be 1.2 or 1.3, got %q", c.TLS.MinVersion)
+	}
+	if c.TLS.RedirectAddr != "" {
+		if !c.TLS.Enabled() {
+			fail("tls.redirect_addr", "requires tls.cert_file and tls.key_file")
+		}
+		if _, _, err := net.SplitHostPort(c.TLS.RedirectAddr); err != nil {
+			fail("tls.redirect_addr", "must be host:port, got %q", c.TLS.RedirectAddr)
+		}
+	}
+	if len(c.Origins()) == 0 {
+		fail("allowed_origins", "set client_url or allowed_origins")
+	} else if _, err := ParseAllowedOrigins(c.Origins()); err != nil {
+		fail("allowed_origins", "%v", err)
+	}
+	if _, err := ParseTrustedProxies(c.TrustedProxies); err != nil {
+		fail("trusted_proxies", "%v", err)
+	}
+	for _, prefix := range c.JoinCallbackAllowlist {
+		u, err := url.Parse(prefix)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("join_callback_allowlist", "must be http(s) URLs, got %q", prefix)
+		}
+	}
+
+	switch c.Cookie.SameSite {
+	case "lax", "strict":
+	case "none":
+		if !c.Cookie.Secure {
+			// ブラウザは Secure のない SameSite=None のクッキーを受け付けない
+			fail("cookie.same_site", "none requires cookie.secure")
+		}
+	default:
+		fail("cookie.same_site", "must be one of lax, strict or none, got %q", c.Cookie.SameSite)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		fail("log.level", "must be one of debug, info, warn or error, got %q", c.Log.Level)
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		fail("log.format", "must be text or json, got %q", c.Log.Format)
+	}
+
+	if c.Rooms.MinTTL.Duration < 0 {
+		fail("rooms.min_ttl", "must not be negative")
+	}
+	if c.Rooms.MaxTTL.Duration <= c.Rooms.MinTTL.Duration {
+		fail("rooms.max_ttl", "must be longer than rooms.min_ttl (%s)", c.Rooms.MinTTL.Duration)
+	}
+	if c.Rooms.MaxParticipants < 2 {
+		fail("rooms.max_participants", "must be at least 2, got %d", c.Rooms.MaxParticipants)
+	}
+	if c.SweepInterval.Duration < time.Second {
+		fail("sweep_interval", "must be at least 1s, got %s", c.SweepInterval.Duration)
+	}
+	return errors.Join(errs...)
+}
+
+// Origins は許可するオリジンの一覧を返す
+// allowed_origins が空なら client_url のみを許可する
+func (c *Config) Origins() []string {
+	if len(c.AllowedOrigins) > 0 {
+		return c.AllowedOrigins
+	}
+	if c.ClientURL != "" {
+		return []string{c.ClientURL}
+	}
+	return nil
+}
+
+// tlsVersions は tls.min_version に書ける値
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Enabled は証明書が設定されていて、HTTPSで提供するかどうかを返す
+func (s TLSSettings) Enabled() bool {
+	return s.CertFile != "" && s.KeyFile != ""
+}
+
+// MinTLSVersion は min_version の設定を tls.VersionTLS12 などに変換する
+func (s TLSSettings) MinTLSVersion() uint16 {
+	if v, ok := tlsVersions[s.MinVersion]; ok {
+		return v
+	}
+	return tls.VersionTLS12
+}
+
+// SameSiteMode は same_site の設定を http.SameSite に変換する
+func (s CookieSettings) SameSiteMode() http.SameSite {
+	switch s.SameSite {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// SlogLevel はログレベルの設定を slog.Level に変換する
+func (s LogSettings) SlogLevel() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s.Level)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile はテスト用の設定ファイルを一時ディレクトリに書き出してパスを返す
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeFile(t, "server.yaml", `
+listen_addr: ":9000"
+client_url: "https://chat.example.com"
+sweep_interval: 1m
+rooms:
+  max_ttl: 48h
+  max_participants: 20
+log:
+  level: warn
+`)
+	tomlFile := writeFile(t, "server.toml", `
+listen_addr = ":9000"
+client_url = "https://chat.example.com"
+sweep_interval = "1m"
+
+[rooms]
+max_ttl = "48h"
+max_participants = 20
+
+[log]
+level = "warn"
+`)
+
+	tests := []*struct {
+		name string
+		env  map[string]string
+		args []string
+		want func(t *testing.T, c *Config)
+	}{
+		{
+			name: "YAMLファイルの値を使う",
+			args: []string{"-config", yamlFile},
+			want: func(t *testing.T, c *Config) {
+				if c.ListenAddr != ":9000" || c.SweepInterval.Duration != time.Minute ||
+					c.Rooms.MaxTTL.Duration != 48*time.Hour || c.Rooms.MaxParticipants != 20 || c.Log.Level != "warn" {
+					t.Errorf("config = %+v", c)
+				}
+				// ファイルに書いていない項目は既定値のまま
+				if !c.Cookie.Secure || c.Cookie.SameSite != "lax" {
+					t.Errorf("cookie = %+v, want defaults", c.Cookie)
+				}
+			},
+		},
+		{
+			name: "TOMLファイルの値を使う",
+			env:  map[string]string{"CONFIG_FILE": tomlFile},
+			want: func(t *testing.T, c *Config) {
+				if c.ListenAddr != ":9000" || c.Rooms.MaxTTL.Duration != 48*time.Hour || c.Log.Level != "warn" {
+					t.Errorf("config = %+v", c)
+				}
+			},
+		},
+		{
+			name: "環境変数はファイルより優先する",
+			env:  map[string]string{"LISTEN_ADDR": ":9100", "ALLOWED_ORIGINS": "https://a.example.com, https://b.example.com"},
+			args: []string{"-config", yamlFile},
+			want: func(t *testing.T, c *Config) {
+				if c.ListenAddr != ":9100" {
+					t.Errorf("ListenAddr = %q, want :9100", c.ListenAddr)
+				}
+				if got := strings.Join(c.Origins(), " "); got != "https://a.example.com https://b.example.com" {
+					t.Errorf("Origins() = %q", got)
+				}
+			},
+		},
+		{
+			name: "引数は環境変数より優先する",
+			env:  map[string]string{"LISTEN_ADDR": ":9100", "MAX_PARTICIPANTS": "30"},
+			args: []string{"-config", yamlFile, "-listen", ":9200"},
+			want: func(t *testing.T, c *Config) {
+				if c.ListenAddr != ":9200" || c.Rooms.MaxParticipants != 30 {
+					t.Errorf("ListenAddr = %q, MaxParticipants = %d", c.ListenAddr, c.Rooms.MaxParticipants)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			c, err := Load(tt.args)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			tt.want(t, c)
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []*struct {
+		name     string
+		file     string
+		content  string
+		env      map[string]string
+		args     []string
+		wantErrs []string // エラーメッセージに含まれるべき文字列
+	}{
+		{
+			name:     "ファイルの知らない項目",
+			file:     "server.yaml",
+			content:  "listen_adr: \":9000\"\n",
+			wantErrs: []string{"listen_adr"},
+		},
+		{
+			name:     "TOMLの知らない項目",
+			file:     "server.toml",
+			content:  "[rooms]\nmax_ttls = \"1h\"\n",
+			wantErrs: []string{"rooms.max_ttls"},
+		},
+		{
+			name:     "対応していない形式",
+			file:     "server.json",
+			content:  "{}",
+			wantErrs: []string{"unsupported format"},
+		},
+		{
+			name:     "環境変数の型の誤り",
+			env:      map[string]string{"MAX_PARTICIPANTS": "many", "COOKIE_SECURE": "yes please"},
+			wantErrs: []string{"MAX_PARTICIPANTS", "COOKIE_SECURE"},
+		},
+		{
+			name:     "TLSの設定の誤り",
+			env:      map[string]string{"TLS_CERT_FILE": "cert.pem", "TLS_MIN_VERSION": "1.0"},
+			args:     []string{"-tls-redirect-addr", ":80"},
+			wantErrs: []string{"set both cert_file and key_file", "tls.min_version", "tls.redirect_addr"},
+		},
+		{
+			name: "検証の誤りをまとめて返す",
+			args: []string{"-listen", "8080", "-room-min-ttl", "2h", "-room-max-ttl", "1h", "-sweep-interval", "10ms"},
+			env:  map[string]string{"COOKIE_SAMESITE": "none", "COOKIE_SECURE": "false", "ALLOWED_ORIGINS": "*"},
+			wantErrs: []string{
+				"listen_addr", "rooms.max_ttl", "sweep_interval", "cookie.same_site", "allowed_origins",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file, tt.content)}, args...)
+			}
+			_, err := Load(args)
+			if err == nil {
+				t.Fatalf("Load() should fail")
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	path := writeFile(t, "server.yaml", "listen_addr: \":9000\"\nadmin_token: first\nrooms:\n  max_participants: 10\n")
+	store, err := NewStore([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("listen_addr: \":9001\"\nadmin_token: second\nrooms:\n  max_participants: 50\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, ignored, err := store.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if c.Rooms.MaxParticipants != 50 {
+		t.Errorf("MaxParticipants = %d, want 50", c.Rooms.MaxParticipants)
+	}
+	if c.ListenAddr != ":9000" || c.AdminToken != "first" {
+		t.Errorf("restart-only fields changed: ListenAddr = %q, AdminToken = %q", c.ListenAddr, c.AdminToken)
+	}
+	if got := strings.Join(ignored, ","); got != "listen_addr,admin_token" {
+		t.Errorf("ignored = %q, want listen_addr,admin_token", got)
+	}
+	if store.Current() != c {
+		t.Errorf("Current() was not replaced")
+	}
+
+	// 誤りのある設定では現在の設定を残す
+	if err := os.WriteFile(path, []byte("rooms:\n  max_participants: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Reload(); err == nil {
+		t.Fatalf("Reload() with an invalid config should fail")
+	}
+	if store.Current() != c {
+		t.Errorf("Current() changed after a failed reload")
+	}
+}
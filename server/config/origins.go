@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// AllowedOrigins はCORSとWebSocketの両方で使う、許可するオリジンの一覧
+// "https://*.example.com" のようにホスト名の先頭を "*." にするとサブドメインすべてに一致する(example.com 自体は含まない)
+type AllowedOrigins struct {
+	patterns []originPattern
+}
+
+type originPattern struct {
+	scheme   string
+	host     string // ワイルドカードの場合は "*." を除いた部分 ("example.com")
+	port     string
+	wildcard bool
+}
+
+// ParseAllowedOrigins は "scheme://host[:port]" 形式のオリジンの一覧を読み込む
+func ParseAllowedOrigins(origins []string) (*AllowedOrigins, error) {
+	a := &AllowedOrigins{}
+	for _, origin := range origins {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		if origin == "*" {
+			// クッキーを使うので、すべてのオリジンを許可することはできない
+			return nil, fmt.Errorf("allowed origin %q is not permitted, list origins explicitly", origin)
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return nil, fmt.Errorf("allowed origin %q must be in the form scheme://host[:port]", origin)
+		}
+		pattern := originPattern{
+			scheme: strings.ToLower(u.Scheme),
+			host:   strings.ToLower(u.Hostname()),
+			port:   normalizePort(u.Scheme, u.Port()),
+		}
+		if strings.HasPrefix(pattern.host, "*.") {
+			pattern.wildcard = true
+			pattern.host = strings.TrimPrefix(pattern.host, "*.")
+		}
+		if pattern.host == "" || strings.Contains(pattern.host, "*") {
+			return nil, fmt.Errorf("allowed origin %q: wildcard is only allowed as the first label (*.example.com)", origin)
+		}
+		a.patterns = append(a.patterns, pattern)
+	}
+	if len(a.patterns) == 0 {
+		return nil, fmt.Errorf("at least one allowed origin is required")
+	}
+	return a, nil
+}
+
+// Check はオリジンが許可されているかを確認し、許可されていなければ理由をエラーとして返す
+func (a *AllowedOrigins) Check(origin string) error {
+	if origin == "null" {
+		return fmt.Errorf("opaque origin %q is not allowed", origin)
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("malformed origin %q", origin)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := normalizePort(scheme, u.Port())
+	for _, p := range a.patterns {
+		if p.scheme != scheme || p.port != port {
+			continue
+		}
+		if p.wildcard {
+			if strings.HasSuffix(host, "."+p.host) {
+				return nil
+			}
+			continue
+		}
+		if host == p.host {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q does not match any allowed origin", origin)
+}
+
+// Allowed はオリジンが許可されているかを返す
+func (a *AllowedOrigins) Allowed(origin string) bool {
+	return a.Check(origin) == nil
+}
+
+// normalizePort は既定のポートを省略した場合と明示した場合を同じに扱う
+func normalizePort(scheme, port string) string {
+	if port != "" {
+		return port
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "ws":
+		return "80"
+	case "https", "wss":
+		return "443"
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package config
+
+import "testing"
+
+func TestAllowedOriginsCheck(t *testing.T) {
+	origins, err := ParseAllowedOrigins([]string{"http://localhost:3000", "https://*.example.com", "https://chat.example.org"})
+	if err != nil {
+		t.Fatalf("ParseAllowedOrigins() error = %v", err)
+	}
+
+	tests := []*struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{name: "exact", origin: "http://localhost:3000", want: true},
+		{name: "wrong port", origin: "http://localhost:3001", want: false},
+		{name: "wrong scheme", origin: "https://localhost:3000", want: false},
+		{name: "subdomain", origin: "https://app.example.com", want: true},
+		{name: "nested subdomain", origin: "https://a.b.example.com", want: true},
+		{name: "explicit default port", origin: "https://app.example.com:443", want: true},
+		{name: "apex is not a subdomain", origin: "https://example.com", want: false},
+		{name: "suffix attack", origin: "https://evilexample.com", want: false},
+		{name: "case insensitive", origin: "https://CHAT.example.org", want: true},
+		{name: "null", origin: "null", want: false},
+		{name: "malformed", origin: "localhost", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := origins.Allowed(tt.origin); got != tt.want {
+				t.Errorf("Allowed(%q) = %v, want %v (reason: %v)", tt.origin, got, tt.want, origins.Check(tt.origin))
+			}
+		})
+	}
+}
+
+func TestParseAllowedOriginsRejectsInvalid(t *testing.T) {
+	for _, origin := range []string{"*", "example.com", "https://ex*ample.com", "https://example.com/path"} {
+		if _, err := ParseAllowedOrigins([]string{origin}); err == nil {
+			t.Errorf("ParseAllowedOrigins(%q) should fail", origin)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies は X-Forwarded-For・X-Real-IP を信用するリバースプロキシのアドレス範囲
+// 一覧にないアドレスからのリクエストではこれらのヘッダーを無視し、接続元のアドレスをクライアントのIPとする
+type TrustedProxies struct {
+	nets []*net.IPNet
+}
+
+// ParseTrustedProxies は "10.0.0.0/8" のようなCIDR、または単一のIPアドレスの一覧を読み込む
+// 空の一覧ならどのプロキシも信用しない
+func ParseTrustedProxies(proxies []string) (*TrustedProxies, error) {
+	p := &TrustedProxies{}
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted proxy %q must be an IP address or CIDR", proxy)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			p.nets = append(p.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q must be an IP address or CIDR", proxy)
+		}
+		p.nets = append(p.nets, ipNet)
+	}
+	return p, nil
+}
+
+// trusted はアドレスが信用するプロキシのものかを返す
+func (p *TrustedProxies) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil || p == nil {
+		return false
+	}
+	for _, n := range p.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP はリクエストを送ったクライアントのIPアドレスを返す
+// 接続元が信用するプロキシの場合に限り、X-Forwarded-For を右から辿って最初の信用しないアドレスを使う
+// (X-Forwarded-For がなければ X-Real-IP を使う)
+// p が nil ならどのプロキシも信用しない
+func (p *TrustedProxies) ClientIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !p.trusted(remote) {
+		return remote
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	if len(hops) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+			return realIP
+		}
+		return remote
+	}
+	// 左側はクライアントが自由に書けるので、プロキシが追加した右側から辿る
+	for i := len(hops) - 1; i >= 0; i-- {
+		if net.ParseIP(hops[i]) == nil {
+			// 書式が壊れている値より左は信用できない
+			return remote
+		}
+		if !p.trusted(hops[i]) {
+			return hops[i]
+		}
+		remote = hops[i]
+	}
+	return remote
+}
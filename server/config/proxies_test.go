@@ -0,0 +1,54 @@
+package config
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxiesClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+
+	tests := []*struct {
+		name      string
+		proxies   *TrustedProxies
+		remote    string
+		forwarded string
+		realIP    string
+		want      string
+	}{
+		{name: "no proxies ignore headers", proxies: nil, remote: "198.51.100.7:1234", forwarded: "203.0.113.9", want: "198.51.100.7"},
+		{name: "untrusted peer ignores headers", proxies: proxies, remote: "198.51.100.7:1234", forwarded: "203.0.113.9", realIP: "203.0.113.8", want: "198.51.100.7"},
+		{name: "trusted proxy", proxies: proxies, remote: "10.1.2.3:1234", forwarded: "203.0.113.9", want: "203.0.113.9"},
+		{name: "spoofed leftmost hop is skipped", proxies: proxies, remote: "10.1.2.3:1234", forwarded: "1.1.1.1, 203.0.113.9", want: "203.0.113.9"},
+		{name: "chain of trusted proxies", proxies: proxies, remote: "10.1.2.3:1234", forwarded: "203.0.113.9, 192.0.2.1, 10.9.9.9", want: "203.0.113.9"},
+		{name: "malformed hop", proxies: proxies, remote: "10.1.2.3:1234", forwarded: "203.0.113.9, bogus", want: "10.1.2.3"},
+		{name: "X-Real-IP from a trusted proxy", proxies: proxies, remote: "10.1.2.3:1234", realIP: "203.0.113.9", want: "203.0.113.9"},
+		{name: "trusted proxy without headers", proxies: proxies, remote: "10.1.2.3:1234", want: "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := tt.proxies.ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesRejectsInvalid(t *testing.T) {
+	for _, proxy := range []string{"proxy.example", "10.0.0.0/33", "10.0.0"} {
+		if _, err := ParseTrustedProxies([]string{proxy}); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) should fail", proxy)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// Store は現在の設定を保持し、SIGHUP などで再読み込みする
+type Store struct {
+	args    []string
+	current atomic.Pointer[Config]
+}
+
+// NewStore は args で設定を読み込み、再読み込みでも同じ args を使う Store を作る
+func NewStore(args []string) (*Store, error) {
+	c, err := Load(args)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{args: args}
+	s.current.Store(c)
+	return s, nil
+}
+
+// Current は現在の設定を返す。返した値を書き換えてはいけない
+func (s *Store) Current() *Config {
+	return s.current.Load()
+}
+
+// Reload は設定を読み直して差し替える
+// 再起動しないと反映できない項目(待ち受けアドレス・TLS・トークン・クッキー)は現在の値のまま残し、
+// 変更されていた項目名を ignored として返す
+// 読み込みや検証に失敗した場合は現在の設定を変えずにエラーを返す
+func (s *Store) Reload() (c *Config, ignored []string, err error) {
+	next, err := Load(s.args)
+	if err != nil {
+		return nil, nil, err
+	}
+	cur := s.Current()
+	for _, f := range restartOnlyFields {
+		if !reflect.DeepEqual(f.get(cur), f.get(next)) {
+			ignored = append(ignored, f.name)
+			f.keep(next, cur)
+		}
+	}
+	s.current.Store(next)
+	return next, ignored, nil
+}
+
+// restartOnlyFields は再読み込みでは変更しない項目
+var restartOnlyFields = []struct {
+	name string
+	get  func(*Config) any
+	keep func(next, cur *Config)
+}{
+	{"listen_addr", func(c *Config) any { return c.ListenAddr }, func(n, c *Config) { n.ListenAddr = c.ListenAddr }},
+	{"tls", func(c *Config) any { return c.TLS }, func(n, c *Config) { n.TLS = c.TLS }},
+	{"admin_token", func(c *Config) any { return c.AdminToken }, func(n, c *Config) { n.AdminToken = c.AdminToken }},
+	{"metrics_token", func(c *Config) any { return c.MetricsToken }, func(n, c *Config) { n.MetricsToken = c.MetricsToken }},
+	{"cookie", func(c *Config) any { return c.Cookie }, func(n, c *Config) { n.Cookie = c.Cookie }},
+}
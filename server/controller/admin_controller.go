@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+
+	"github.com/takaryo1010/OneTimeChat/server/logging"
+)
+
+// 運用者向けのAPI。router で管理用トークンを確認してから呼ばれる
+
+// 部屋の一覧(参加者数・接続数・有効期限)
+func (mc *MainController) AdminListRooms(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{"rooms": mc.RoomUsecase.AdminListRooms()})
+}
+
+// 部屋の参加者と接続状況
+func (mc *MainController) AdminGetRoom(c echo.Context) error {
+	roomID := c.Param("id")
+	room, err := mc.RoomUsecase.AdminGetRoom(roomID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, room)
+}
+
+// 部屋を強制的に閉じる
+// 理由は reason クエリで指定する
+func (mc *MainController) AdminCloseRoom(c echo.Context) error {
+	roomID := c.Param("id")
+	reason := c.QueryParam("reason")
+	err := mc.RoomUsecase.AdminCloseRoom(roomID, reason)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	mc.logger(c).Info("room closed by operator")
+	return c.JSON(http.StatusOK, map[string]string{"message": "room closed"})
+}
+
+// クライアントの接続を切る(セッションは残る)
+// 理由は reason クエリで指定する
+func (mc *MainController) AdminDisconnectClient(c echo.Context) error {
+	roomID := c.Param("id")
+	clientID := c.Param("clientID")
+	reason := c.QueryParam("reason")
+	err := mc.RoomUsecase.AdminDisconnectClient(roomID, clientID, reason)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	mc.logger(c).Info("client disconnected by operator", logging.KeyClientID, clientID)
+	return c.JSON(http.StatusOK, map[string]string{"message": "client disconnected"})
+}
+
+// サーバー全体の集計
+func (mc *MainController) AdminStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, mc.RoomUsecase.AdminStats())
+}
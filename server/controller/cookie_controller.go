@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/takaryo1010/OneTimeChat/server/config"
+)
+
+// セッション用クッキーの有効期限
+const cookieTTL = 24 * time.Hour
+
+// CookieConfig はサーバーが発行するクッキーの属性の設定
+type CookieConfig struct {
+	HTTPOnly bool          // セッションIDのクッキーをJavaScriptから読めないようにする
+	Secure   bool          // HTTPSでのみ送信する(localhost はHTTPでも送られる)
+	SameSite http.SameSite // クロスサイトのリクエストで送るかどうか
+}
+
+// DefaultCookieConfig は安全側に倒したクッキーの既定値を返す
+func DefaultCookieConfig() CookieConfig {
+	return CookieConfig{
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// NewCookieConfig は設定ファイル・環境変数から読んだクッキーの設定を変換する
+func NewCookieConfig(settings config.CookieSettings) CookieConfig {
+	return CookieConfig{
+		HTTPOnly: settings.HTTPOnly,
+		Secure:   settings.Secure,
+		SameSite: settings.SameSiteMode(),
+	}
+}
+
+// roomCookieName は部屋ごとのクッキー名を返す ("session_id_<roomID>" など)
+// 部屋IDは英大文字と数字だけなのでそのままクッキー名に使える
+func roomCookieName(key, roomID string) string {
+	return key + "_" + roomID
+}
+
+// newCookie は設定した属性を付けたクッキーを作る
+// httpOnly が false のクッキーはフロントエンドが表示に使うもので、認証には使わない
+func (mc *MainController) newCookie(name, value string, expires time.Time, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: httpOnly && mc.Cookies.HTTPOnly,
+		Secure:   mc.Cookies.Secure,
+		SameSite: mc.Cookies.SameSite,
+	}
+}
+
+// setRoomCookies は部屋ごとのセッション・表示名・オーナーかどうかをクッキーに保存する
+// 同じブラウザで別の部屋に参加しても、前の部屋のセッションは上書きされない
+// room_id・user_name・is_owner は最後に開いた部屋を示すだけの表示用で、認証には使わない
+func (mc *MainController) setRoomCookies(c echo.Context, roomID, sessionID, userName string, isOwner bool) {
+	expires := time.Now().Add(cookieTTL)
+	c.SetCookie(mc.newCookie(roomCookieName("session_id", roomID), sessionID, expires, true))
+	for _, v := range []struct {
+		name  string
+		value string
+	}{
+		{roomCookieName("user_name", roomID), url.QueryEscape(userName)},
+		{roomCookieName("is_owner", roomID), strconv.FormatBool(isOwner)},
+		{"room_id", roomID},
+		{"user_name", url.QueryEscape(userName)},
+		{"is_owner", strconv.FormatBool(isOwner)},
+	} {
+		c.SetCookie(mc.newCookie(v.name, v.value, expires, false))
+	}
+}
+
+// clearRoomCookies は退出・削除した部屋のクッキーを消す
+func (mc *MainController) clearRoomCookies(c echo.Context, roomID string) {
+	for _, key := range []string{"session_id", "user_name", "is_owner"} {
+		cookie := mc.newCookie(roomCookieName(key, roomID), "", time.Unix(0, 0), key == "session_id")
+		cookie.MaxAge = -1
+		c.SetCookie(cookie)
+	}
+}
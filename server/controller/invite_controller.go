@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// 招待の発行(オーナー・モデレーター用)
+func (mc *MainController) CreateInvite(c echo.Context) error {
+	roomID := c.Param("id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	type CreateInviteRequest struct {
+		MaxUses          int  `json:"maxUses"`          // 0は無制限
+		ExpiresInSeconds int  `json:"expiresInSeconds"` // 0は既定の期間
+		PreApproved      bool `json:"preApproved"`
+	}
+	var req CreateInviteRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	invite, err := mc.RoomUsecase.CreateInvite(roomID, sessionID, req.MaxUses, time.Duration(req.ExpiresInSeconds)*time.Second, req.PreApproved)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	mc.logger(c).Info("invite created")
+	return c.JSON(http.StatusOK, invite)
+}
+
+// 招待の一覧(オーナー・モデレーター用)
+func (mc *MainController) ListInvites(c echo.Context) error {
+	roomID := c.Param("id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+
+	invites, err := mc.RoomUsecase.ListInvites(roomID, sessionID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"invites": invites})
+}
+
+// 招待の取り消し(オーナー・モデレーター用)
+func (mc *MainController) RevokeInvite(c echo.Context) error {
+	roomID := c.Param("id")
+	token := c.Param("token")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+
+	err = mc.RoomUsecase.RevokeInvite(roomID, sessionID, token)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	mc.logger(c).Info("invite revoked")
+	return c.JSON(http.StatusOK, map[string]string{"message": "invite revoked"})
+}
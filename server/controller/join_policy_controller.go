@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// 参加ポリシーの設定(オーナー専用)
+// 指定した一覧で置き換える。空の一覧を渡すとポリシーを解除する
+func (mc *MainController) SetJoinPolicies(c echo.Context) error {
+	roomID := c.Param("id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	type SetJoinPoliciesRequest struct {
+		Policies []model.JoinPolicySpec `json:"policies"`
+	}
+	var req SetJoinPoliciesRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	policies, err := mc.RoomUsecase.SetJoinPolicies(roomID, sessionID, req.Policies)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	mc.logger(c).Info("join policies updated")
+	return c.JSON(http.StatusOK, map[string]interface{}{"policies": policies})
+}
+
+// 参加ポリシーの一覧(オーナー・モデレーター用)
+func (mc *MainController) GetJoinPolicies(c echo.Context) error {
+	roomID := c.Param("id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+
+	policies, err := mc.RoomUsecase.GetJoinPolicies(roomID, sessionID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"policies": policies})
+}
+
+// 参加ポリシーによる判定の記録(オーナー・モデレーター用)
+func (mc *MainController) ListJoinDecisions(c echo.Context) error {
+	roomID := c.Param("id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+
+	decisions, err := mc.RoomUsecase.ListJoinDecisions(roomID, sessionID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"decisions": decisions})
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+
+	"github.com/takaryo1010/OneTimeChat/server/logging"
+)
+
+// DenyClient rejects a pending join request.
+// 参加待ちのクライアントを拒否する(オーナー・モデレーター用)。理由は任意
+func (mc *MainController) DenyClient(c echo.Context) error {
+	roomID := c.Param("id")
+	clientID := c.QueryParam("client_id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	if clientID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "client_id is required"})
+	}
+	// 理由は任意なので、ボディがなければクエリパラメータを見る
+	reason := c.QueryParam("reason")
+	if c.Request().ContentLength != 0 {
+		type DenyRequest struct {
+			Reason string `json:"reason"`
+		}
+		var req DenyRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		if req.Reason != "" {
+			reason = req.Reason
+		}
+	}
+
+	err = mc.RoomUsecase.DenyClient(roomID, sessionID, clientID, reason)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	mc.logger(c).Info("client denied", logging.KeyClientID, clientID)
+	return c.JSON(http.StatusOK, map[string]string{"message": "client denied"})
+}
+
+// ApproveClients approves several pending join requests at once.
+func (mc *MainController) ApproveClients(c echo.Context) error {
+	roomID := c.Param("id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	type BulkApproveRequest struct {
+		ClientIDs []string `json:"client_ids"`
+	}
+	var req BulkApproveRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+	if len(req.ClientIDs) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "client_ids is required"})
+	}
+
+	result, err := mc.RoomUsecase.ApproveClients(roomID, sessionID, req.ClientIDs)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	mc.logger(c).Info("clients approved", "count", len(result.Succeeded))
+	return c.JSON(http.StatusOK, result)
+}
+
+// DenyClients rejects several pending join requests at once.
+func (mc *MainController) DenyClients(c echo.Context) error {
+	roomID := c.Param("id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	type BulkDenyRequest struct {
+		ClientIDs []string `json:"client_ids"`
+		Reason    string   `json:"reason"`
+	}
+	var req BulkDenyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+	if len(req.ClientIDs) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "client_ids is required"})
+	}
+
+	result, err := mc.RoomUsecase.DenyClients(roomID, sessionID, req.ClientIDs, req.Reason)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	mc.logger(c).Info("clients denied", "count", len(result.Succeeded))
+	return c.JSON(http.StatusOK, result)
+}
+
+// CancelJoinRequest lets a pending client withdraw its own join request.
+func (mc *MainController) CancelJoinRequest(c echo.Context) error {
+	roomID := c.Param("id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+
+	err = mc.RoomUsecase.CancelJoinRequest(roomID, sessionID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	mc.clearRoomCookies(c, roomID)
+	mc.logger(c).Info("join request cancelled")
+	return c.JSON(http.StatusOK, map[string]string{"message": "join request cancelled"})
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/takaryo1010/OneTimeChat/server/logging"
+)
+
+// 参加者をミュート(オーナー・モデレーター用)
+// duration は秒数で、0または省略で解除するまで無期限
+func (mc *MainController) MuteClient(c echo.Context) error {
+	roomID := c.Param("id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	type MuteRequest struct {
+		ClientID string `json:"client_id"`
+		Duration int    `json:"duration"`
+	}
+	var req MuteRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+	if req.ClientID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "client_id is required"})
+	}
+
+	err = mc.RoomUsecase.MuteClient(roomID, sessionID, req.ClientID, time.Duration(req.Duration)*time.Second)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	mc.logger(c).Info("client muted", logging.KeyClientID, req.ClientID)
+	return c.JSON(http.StatusOK, map[string]string{"message": "client muted"})
+}
+
+// 参加者のミュートを解除(オーナー・モデレーター用)
+func (mc *MainController) UnmuteClient(c echo.Context) error {
+	roomID := c.Param("id")
+	clientID := c.QueryParam("client_id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	if clientID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "client_id is required"})
+	}
+
+	err = mc.RoomUsecase.UnmuteClient(roomID, sessionID, clientID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	mc.logger(c).Info("client unmuted", logging.KeyClientID, clientID)
+	return c.JSON(http.StatusOK, map[string]string{"message": "client unmuted"})
+}
@@ -1,18 +1,27 @@
 package controller
 
 import (
-	"fmt"
+	"errors"
+	"log/slog"
 	"net/http"
-	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/labstack/echo"
+	"github.com/takaryo1010/OneTimeChat/server/logging"
 	"github.com/takaryo1010/OneTimeChat/server/model"
 	"github.com/takaryo1010/OneTimeChat/server/usecase"
+	"github.com/takaryo1010/OneTimeChat/server/validator"
 )
 
 type MainController struct {
 	RoomUsecase *usecase.RoomUsecase
+	Cookies     CookieConfig // 発行するクッキーの属性
+}
+
+// logger はリクエストID・部屋IDが付いたリクエストのロガーを返す
+func (mc *MainController) logger(c echo.Context) *slog.Logger {
+	return logging.FromContext(c.Request().Context())
 }
 
 func (mc *MainController) CreateRoom(c echo.Context) error {
@@ -23,42 +32,25 @@ func (mc *MainController) CreateRoom(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
 	}
 
-	roomName := req.Name
-	owner := req.Owner
-	fmt.Println("Room name:", roomName, "by", owner)
 	// ルーム作成処理
 	room, sessionID, err := mc.RoomUsecase.CreateRoom(&req)
+	if errors.Is(err, validator.ErrInvalidName) || errors.Is(err, usecase.ErrInvalidSettings) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	// セッションIDをクッキーに保存
-	c.SetCookie(&http.Cookie{
-		Name:    "session_id",
-		Value:   sessionID,
-		Path:    "/",
-		Expires: time.Now().Add(24 * time.Hour), // セッションの有効期限
-	})
-	c.SetCookie(&http.Cookie{
-		Name:    "room_id",
-		Value:   room.ID, // ここにRoomIDを設定
-		Path:    "/",
-		Expires: time.Now().Add(24 * time.Hour), // セッションの有効期限
-	})
-	c.SetCookie(&http.Cookie{
-		Name:    "user_name",
-		Value:   url.QueryEscape(owner),
-		Path:    "/",
-		Expires: time.Now().Add(24 * time.Hour), // セッションの有効期限
-	})
+	// セッションIDを部屋ごとのクッキーに保存
+	mc.setRoomCookies(c, room.ID, sessionID, room.Owner, true)
+	// Cookieを使わないクライアント向けのトークン
+	token, err := mc.RoomUsecase.IssueToken(room.ID, sessionID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	room.Token = token
 
-	c.SetCookie(&http.Cookie{
-		Name:    "is_owner",
-		Value:   "true",
-		Path:    "/",
-		Expires: time.Now().Add(24 * time.Hour), // セッションの有効期限
-	})
-	fmt.Println("Room created:", room.ID)
+	mc.logger(c).Info("room created", logging.KeyRoomID, room.ID, logging.Name(room.Owner))
 	// ルーム作成成功時に返す
 	return c.JSON(http.StatusOK, room)
 }
@@ -76,64 +68,69 @@ func (mc *MainController) GetRoom(c echo.Context) error {
 // JoinRoom allows a client to join a room.
 func (mc *MainController) JoinRoom(c echo.Context) error {
 	roomID := c.Param("id")
-	type JoinRoomRequest struct {
-		ClientName string `json:"client_name"`
-	}
-	var req JoinRoomRequest
+	var req model.JoinRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
 	}
+	req.ClientIP = mc.RoomUsecase.ClientIP(c.Request())
+	// キックと同時に参加禁止になったクライアントを見分けるため、以前の参加のセッションを渡す
+	req.SessionID = sessionCookie(c, roomID)
+	req.Token = bearerToken(c)
+	if req.InviteToken == "" {
+		// 招待リンク(?invite=...)から来た場合
+		req.InviteToken = c.QueryParam("invite")
+	}
+	sessionID, err := mc.RoomUsecase.JoinRoom(c.Request().Context(), roomID, &req)
+	if errors.Is(err, validator.ErrInvalidName) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, usecase.ErrTooManyAttempts) {
+		return c.JSON(http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, usecase.ErrInvalidPassword) || errors.Is(err, usecase.ErrBanned) ||
+		errors.Is(err, usecase.ErrRoomLocked) || errors.Is(err, usecase.ErrJoinDenied) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, usecase.ErrRoomFull) || errors.Is(err, validator.ErrNameTaken) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	// 重複した名前には番号が付くので、実際に使われた名前を返す
 	clientName := req.ClientName
 
-	sessionID, err := mc.RoomUsecase.JoinRoom(roomID, clientName)
+	// セッションIDを部屋ごとのクッキーに保存
+	mc.setRoomCookies(c, roomID, sessionID, clientName, false)
+	// Cookieを使わないクライアント向けのトークン
+	token, err := mc.RoomUsecase.IssueToken(roomID, sessionID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	// セッションIDをクッキーに保存
-	c.SetCookie(&http.Cookie{
-		Name:    "session_id",
-		Value:   sessionID,
-		Path:    "/",
-		Expires: time.Now().Add(24 * time.Hour), // セッションの有効期限
-	})
-	c.SetCookie(&http.Cookie{
-		Name:    "room_id",
-		Value:   roomID, // ここにRoomIDを設定
-		Path:    "/",
-		Expires: time.Now().Add(24 * time.Hour), // セッションの有効期限
-	})
-	c.SetCookie(&http.Cookie{
-		Name:    "user_name",
-		Value:   url.QueryEscape(clientName),
-		Path:    "/",
-		Expires: time.Now().Add(24 * time.Hour), // セッションの有効期限
-	})
-	c.SetCookie(&http.Cookie{
-		Name:    "is_owner",
-		Value:   "false",
-		Path:    "/",
-		Expires: time.Now().Add(24 * time.Hour), // セッションの有効期限
-	})
 	// 部屋に参加したことを確認
-	fmt.Println("Client joined:", clientName, "in room:", roomID)
+	mc.logger(c).Info("client joined", logging.Name(clientName))
 
-	return c.JSON(http.StatusOK, map[string]string{"roomID": roomID, "sessionID": sessionID})
+	return c.JSON(http.StatusOK, map[string]string{"roomID": roomID, "sessionID": sessionID, "token": token, "clientName": clientName})
 }
 
 // Authenticate authenticates a client to join a room.
+// オーナーとモデレーターが承認できる
 func (mc *MainController) Authenticate(c echo.Context) error {
 	roomID := c.Param("id")
 	clientID := c.QueryParam("client_id")
-	ownerSessionID := GetCookie(c, "session_id")
-	if ownerSessionID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "session_id is required"})
+	ownerSessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	err = mc.RoomUsecase.Authenticate(roomID, clientID, ownerSessionID)
+	if errors.Is(err, usecase.ErrRoomFull) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
 	}
-	err := mc.RoomUsecase.Authenticate(roomID, clientID, ownerSessionID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-	fmt.Println("Client authenticated:", clientID, "in room:", roomID)
+	mc.logger(c).Info("client approved", logging.KeyClientID, clientID)
 	return nil
 }
 
@@ -142,10 +139,6 @@ func (mc *MainController) GetParticipants(c echo.Context) error {
 	roomID := c.Param("id")
 	authenticatedClients, unauthenticatedClients, err := mc.RoomUsecase.GetParticipants(roomID)
 
-	for _, client := range authenticatedClients {
-		fmt.Println("Client:", client.Name)
-	}
-
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -160,81 +153,189 @@ func (mc *MainController) GetParticipants(c echo.Context) error {
 // RoomNameとrequiresAuthをjsonで必ず受け取る
 func (mc *MainController) UpdateRoomSettings(c echo.Context) error {
 	roomID := c.Param("id")
-	ownerSessionID := GetCookie(c, "session_id")
-	if ownerSessionID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "session_id is required"})
+	ownerSessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
 	}
-	var req model.Room
+	var req model.RoomSettings
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
 	}
 	// ルーム作成処理
 	room, err := mc.RoomUsecase.UpdateRoomSettings(roomID, &req, ownerSessionID)
+	if errors.Is(err, validator.ErrInvalidName) || errors.Is(err, usecase.ErrInvalidSettings) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-	fmt.Println("Room updated:", room.ID)
+	mc.logger(c).Info("room settings updated")
 	return c.JSON(http.StatusOK, room)
 }
 
 // ルームの削除(オーナー専用)
 func (mc *MainController) DeleteRoom(c echo.Context) error {
 	roomID := c.Param("id")
-	ownerSessionID := GetCookie(c, "session_id")
-	if ownerSessionID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "session_id is required"})
+	ownerSessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
 	}
-	err := mc.RoomUsecase.DeleteRoom(roomID, ownerSessionID)
+	err = mc.RoomUsecase.DeleteRoom(roomID, ownerSessionID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-	fmt.Println("Room deleted:", roomID)
+	mc.clearRoomCookies(c, roomID)
+	mc.logger(c).Info("room deleted")
 	return c.JSON(http.StatusOK, map[string]string{"message": "room deleted"})
 }
 
-// 参加者をキック(オーナー専用)
+// 参加者をキック(オーナー・モデレーター用)
 func (mc *MainController) KickParticipant(c echo.Context) error {
 	roomID := c.Param("id")
-	ownerSessionID := GetCookie(c, "session_id")
 	clientID := c.QueryParam("client_id")
 
-	if ownerSessionID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "session_id is required"})
+	ownerSessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
 	}
 
 	if clientID == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "client_session_id is required"})
 	}
 
-	err := mc.RoomUsecase.KickParticipant(roomID, clientID, ownerSessionID)
+	// ban=true の場合はキックと同時に参加禁止にする
+	// duration(秒)を省略すると無期限、ban_ip・ban_name で対象を広げられる
+	var ban *usecase.BanOptions
+	if c.QueryParam("ban") == "true" {
+		ban = &usecase.BanOptions{
+			ByIP:   c.QueryParam("ban_ip") == "true",
+			ByName: c.QueryParam("ban_name") == "true",
+			Reason: c.QueryParam("reason"),
+		}
+		if durationParam := c.QueryParam("duration"); durationParam != "" {
+			seconds, err := strconv.Atoi(durationParam)
+			if err != nil || seconds < 0 {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid duration"})
+			}
+			ban.Duration = time.Duration(seconds) * time.Second
+		}
+	}
+
+	err = mc.RoomUsecase.KickParticipant(roomID, clientID, ownerSessionID, ban)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-	fmt.Println("Client kicked:", clientID, "in room:", roomID)
+	mc.logger(c).Info("client kicked", logging.KeyClientID, clientID)
 	return c.JSON(http.StatusOK, map[string]string{"message": "client kicked"})
 }
 
+// オーナー権限の譲渡(オーナー専用)
+func (mc *MainController) TransferOwnership(c echo.Context) error {
+	roomID := c.Param("id")
+	ownerSessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	type TransferOwnershipRequest struct {
+		ClientID string `json:"client_id"`
+	}
+	var req TransferOwnershipRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+	if req.ClientID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "client_id is required"})
+	}
+
+	err = mc.RoomUsecase.TransferOwnership(roomID, ownerSessionID, req.ClientID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	mc.logger(c).Info("ownership transferred", logging.KeyClientID, req.ClientID)
+	return c.JSON(http.StatusOK, map[string]string{"message": "ownership transferred"})
+}
+
+// 参加者のロール変更(オーナー専用)
+func (mc *MainController) SetRole(c echo.Context) error {
+	roomID := c.Param("id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	type SetRoleRequest struct {
+		ClientID string     `json:"client_id"`
+		Role     model.Role `json:"role"`
+	}
+	var req SetRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+	if req.ClientID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "client_id is required"})
+	}
+
+	err = mc.RoomUsecase.SetRole(roomID, sessionID, req.ClientID, req.Role)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	mc.logger(c).Info("role changed", logging.KeyClientID, req.ClientID, "role", req.Role)
+	return c.JSON(http.StatusOK, map[string]string{"message": "role changed"})
+}
+
+// 参加禁止の一覧(オーナー・モデレーター用)
+func (mc *MainController) ListBans(c echo.Context) error {
+	roomID := c.Param("id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+
+	bans, err := mc.RoomUsecase.ListBans(roomID, sessionID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"bans": bans})
+}
+
+// 参加禁止の解除(オーナー・モデレーター用)
+func (mc *MainController) LiftBan(c echo.Context) error {
+	roomID := c.Param("id")
+	banID := c.Param("banID")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+
+	err = mc.RoomUsecase.LiftBan(roomID, sessionID, banID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	mc.logger(c).Info("ban lifted", "ban_id", banID)
+	return c.JSON(http.StatusOK, map[string]string{"message": "ban lifted"})
+}
+
 // ルームから退出
 func (mc *MainController) LeaveRoom(c echo.Context) error {
 	roomID := c.Param("id")
-	clientSessionID := GetCookie(c, "session_id")
-	if clientSessionID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "session_id is required"})
+	clientSessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
 	}
-	err := mc.RoomUsecase.LeaveRoom(roomID, clientSessionID)
+	err = mc.RoomUsecase.LeaveRoom(roomID, clientSessionID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-	fmt.Println("Client left:", clientSessionID, "in room:", roomID)
+	mc.clearRoomCookies(c, roomID)
+	mc.logger(c).Info("client left")
 	return c.JSON(http.StatusOK, map[string]string{"message": "client left"})
 }
 
 // 認証状態を確認
 func (mc *MainController) IsAuth(c echo.Context) error {
 	roomID := c.Param("id")
-	clientSessionID := GetCookie(c, "session_id")
-	if clientSessionID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "session_id is required"})
+	clientSessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
 	}
 	isAuth, err := mc.RoomUsecase.IsAuth(roomID, clientSessionID)
 	if err != nil {
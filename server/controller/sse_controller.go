@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/takaryo1010/OneTimeChat/server/usecase"
+)
+
+// Events streams room events as Server-Sent Events.
+// WebSocketが使えない環境向けで、Last-Event-ID ヘッダー(または lastEventId クエリ)で再開できる
+func (mc *MainController) Events(c echo.Context) error {
+	roomID := c.Param("id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+
+	lastEventIDParam := c.Request().Header.Get("Last-Event-ID")
+	if lastEventIDParam == "" {
+		lastEventIDParam = c.QueryParam("lastEventId")
+	}
+	var lastEventID int64
+	if lastEventIDParam != "" {
+		id, err := strconv.ParseInt(lastEventIDParam, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid Last-Event-ID"})
+		}
+		lastEventID = id
+	}
+
+	err = mc.RoomUsecase.StreamEvents(c.Response(), c.Request(), roomID, sessionID, mc.RoomUsecase.ClientIP(c.Request()), lastEventID)
+	if err != nil {
+		if c.Response().Committed {
+			return nil
+		}
+		if errors.Is(err, usecase.ErrBanned) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return nil
+}
+
+// SendMessage sends a chat message over plain HTTP.
+func (mc *MainController) SendMessage(c echo.Context) error {
+	roomID := c.Param("id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	type SendMessageRequest struct {
+		Content string `json:"content"`
+	}
+	var req SendMessageRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+	if req.Content == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "content is required"})
+	}
+
+	err = mc.RoomUsecase.SendMessage(roomID, sessionID, req.Content)
+	var chatErr *usecase.ChatError
+	if errors.As(err, &chatErr) {
+		return chatErrorResponse(c, chatErr)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	mc.logger(c).Debug("message sent over HTTP")
+	return c.JSON(http.StatusOK, map[string]string{"message": "message sent"})
+}
+
+// SendReaction はHTTP経由でメッセージにリアクションを送る
+func (mc *MainController) SendReaction(c echo.Context) error {
+	roomID := c.Param("id")
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	type SendReactionRequest struct {
+		MessageID int64  `json:"message_id"`
+		Content   string `json:"content"`
+	}
+	var req SendReactionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	err = mc.RoomUsecase.SendReaction(roomID, sessionID, req.MessageID, req.Content)
+	var chatErr *usecase.ChatError
+	if errors.As(err, &chatErr) {
+		return chatErrorResponse(c, chatErr)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "reaction sent"})
+}
+
+// chatErrorResponse はメッセージ・リアクションを送れなかった理由を、WebSocketのエラーフレームと同じ code と until で返す
+func chatErrorResponse(c echo.Context, chatErr *usecase.ChatError) error {
+	res := map[string]interface{}{"error": chatErr.Error(), "code": chatErr.Code}
+	if !chatErr.Until.IsZero() {
+		res["until"] = chatErr.Until.Unix()
+	}
+	switch {
+	case errors.Is(chatErr, usecase.ErrSlowMode):
+		retryAfter := int(time.Until(chatErr.Until).Seconds()) + 1
+		c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		return c.JSON(http.StatusTooManyRequests, res)
+	case errors.Is(chatErr, usecase.ErrMuted), errors.Is(chatErr, usecase.ErrAnnouncementOnly):
+		return c.JSON(http.StatusForbidden, res)
+	}
+	return c.JSON(http.StatusBadRequest, res)
+}
@@ -1,6 +1,14 @@
 package controller
 
-import "github.com/labstack/echo"
+import (
+	"errors"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// WebSocketのサブプロトコルでトークンを渡す場合の接頭辞 ("bearer.<token>")
+const bearerSubprotocolPrefix = "bearer."
 
 func GetCookie(c echo.Context, key string) string {
 	cookie, err := c.Cookie(key)
@@ -9,3 +17,51 @@ func GetCookie(c echo.Context, key string) string {
 	}
 	return cookie.Value
 }
+
+// bearerToken は Authorization: Bearer ヘッダー、または Sec-WebSocket-Protocol の
+// "bearer.<token>" からトークンを取り出す。どちらもなければ空文字を返す
+func bearerToken(c echo.Context) string {
+	req := c.Request()
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		scheme, token, found := strings.Cut(auth, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	for _, value := range req.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(value, ",") {
+			protocol = strings.TrimSpace(protocol)
+			if strings.HasPrefix(protocol, bearerSubprotocolPrefix) {
+				return strings.TrimPrefix(protocol, bearerSubprotocolPrefix)
+			}
+		}
+	}
+	return ""
+}
+
+// sessionCookie は部屋のクッキー session_id_<roomID>、なければ以前の共通クッキー session_id の値を返す
+// セッションが有効かどうかは確認しない
+func sessionCookie(c echo.Context, roomID string) string {
+	if sessionID := GetCookie(c, roomCookieName("session_id", roomID)); sessionID != "" {
+		return sessionID
+	}
+	return GetCookie(c, "session_id")
+}
+
+// sessionForRoom はリクエストのセッションIDを取得し、セッションレジストリで部屋に対して有効か確認する
+// Bearerトークンがあればそちらを優先し、なければURLの部屋のクッキー session_id_<roomID> を使う
+// 部屋ごとのクッキーがない場合は、以前の共通クッキー session_id を使う
+func (mc *MainController) sessionForRoom(c echo.Context, roomID string) (string, error) {
+	if token := bearerToken(c); token != "" {
+		return mc.RoomUsecase.ResolveToken(roomID, token)
+	}
+
+	sessionID := sessionCookie(c, roomID)
+	if sessionID == "" {
+		return "", errors.New("session_id is required")
+	}
+	if _, err := mc.RoomUsecase.ValidateSession(roomID, sessionID); err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
@@ -1,28 +1,32 @@
 package controller
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
 
 	"github.com/labstack/echo"
+	"github.com/takaryo1010/OneTimeChat/server/usecase"
 )
 
 // WebSocketHandler handles WebSocket connections.
 func (mc *MainController) WebSocketHandler(c echo.Context) error {
 	roomID := c.QueryParam("room_id")
-	clientName := c.QueryParam("client_name")
-	sessionID := GetCookie(c, "session_id")
-	if roomID == "" || clientName == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "room_id and client_name are required"})
+	// client_name は古いクライアントとの互換のために受け付けるが使わない(送信者はセッションから決まる)
+	if roomID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "room_id is required"})
 	}
-	if sessionID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "session_id is required"})
+	sessionID, err := mc.sessionForRoom(c, roomID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	err = mc.RoomUsecase.HandleWebSocketConnection(c.Response(), c.Request(), roomID, sessionID, mc.RoomUsecase.ClientIP(c.Request()))
+	if errors.Is(err, usecase.ErrBanned) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
 	}
-	err := mc.RoomUsecase.HandleWebSocketConnection(c.Response(), c.Request(), roomID, clientName, sessionID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 	//ユーザーがWebSocketに接続したときにログを出力
-	fmt.Printf("%s connected to WebSocket\n", clientName)
+	mc.logger(c).Debug("WebSocket connection upgraded")
 	return nil
 }
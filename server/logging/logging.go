@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// ログの属性のキー
+// 秘密の情報や個人情報は必ずこのキーで出力し、Handler がまとめて伏せ字にできるようにする
+const (
+	KeyRoomID    = "room_id"
+	KeyClientID  = "client_id"
+	KeyRequestID = "request_id"
+	KeySessionID = "session_id" // 伏せ字にする(指紋のみ出力)
+	KeyContent   = "content"    // 伏せ字にする
+	KeyName      = "name"       // プライバシーモードでは出力しない
+	KeyIP        = "ip"         // プライバシーモードでは出力しない
+)
+
+// redacted は伏せ字にした値
+const redacted = "[REDACTED]"
+
+// Config はログ出力の設定
+type Config struct {
+	Level   slog.Level // 出力する最低レベル
+	JSON    bool       // JSON形式で出力する(false ならテキスト形式)
+	Redact  bool       // セッションIDとメッセージ本文を伏せ字にする
+	Privacy bool       // 参加者の名前とIPアドレスを出力しない
+}
+
+// DefaultConfig はログ出力の既定値を返す
+func DefaultConfig() Config {
+	return Config{
+		Level:  slog.LevelInfo,
+		Redact: true,
+	}
+}
+
+// New は設定に従って伏せ字の処理を行うロガーを作る
+func New(w io.Writer, config Config) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       config.Level,
+		ReplaceAttr: replaceAttr(config),
+	}
+	if config.JSON {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// replaceAttr は属性のキーを見て伏せ字にする、または取り除く
+func replaceAttr(config Config) func(groups []string, a slog.Attr) slog.Attr {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		switch a.Key {
+		case KeySessionID:
+			if config.Redact {
+				return slog.String(a.Key, Fingerprint(a.Value.String()))
+			}
+		case KeyContent:
+			if config.Redact {
+				return slog.String(a.Key, redacted)
+			}
+		case KeyName, KeyIP:
+			if config.Privacy {
+				return slog.Attr{}
+			}
+		}
+		return a
+	}
+}
+
+// Fingerprint はセッションIDなどの秘密の値から、ログ同士の突き合わせに使える短い指紋を作る
+// 元の値は復元できない
+func Fingerprint(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return "sha256:" + hex.EncodeToString(sum[:4])
+}
+
+// SessionID はセッションIDの属性を作る
+func SessionID(id string) slog.Attr { return slog.String(KeySessionID, id) }
+
+// Content はメッセージ本文の属性を作る
+func Content(content string) slog.Attr { return slog.String(KeyContent, content) }
+
+// Name は参加者の表示名の属性を作る
+func Name(name string) slog.Attr { return slog.String(KeyName, name) }
+
+// IP はクライアントのIPアドレスの属性を作る
+func IP(ip string) slog.Attr { return slog.String(KeyIP, ip) }
+
+type contextKey struct{}
+
+// WithContext はリクエストの情報を付けたロガーをコンテキストに入れる
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext はコンテキストのロガーを返す。なければ既定のロガーを返す
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Fatal はエラーを出力してプロセスを終了する
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
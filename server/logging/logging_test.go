@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestNewRedaction(t *testing.T) {
+	const sessionID = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	tests := []*struct {
+		name   string
+		config Config
+		want   map[string]any // 出力されるべき属性(nil は出力されないこと)
+	}{
+		{
+			name:   "既定ではセッションIDと本文を伏せ字にする",
+			config: DefaultConfig(),
+			want: map[string]any{
+				KeySessionID: Fingerprint(sessionID),
+				KeyContent:   redacted,
+				KeyName:      "alice",
+				KeyIP:        "192.0.2.1",
+				KeyRoomID:    "ABCDE",
+			},
+		},
+		{
+			name:   "プライバシーモードでは名前とIPを出力しない",
+			config: Config{Redact: true, Privacy: true},
+			want: map[string]any{
+				KeySessionID: Fingerprint(sessionID),
+				KeyContent:   redacted,
+				KeyName:      nil,
+				KeyIP:        nil,
+				KeyRoomID:    "ABCDE",
+			},
+		},
+		{
+			name:   "伏せ字を無効にできる",
+			config: Config{Redact: false},
+			want: map[string]any{
+				KeySessionID: sessionID,
+				KeyContent:   "hello",
+				KeyName:      "alice",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.JSON = true
+			var buf bytes.Buffer
+			logger := New(&buf, tt.config).With(KeyRoomID, "ABCDE", IP("192.0.2.1"))
+			logger.Info("test", SessionID(sessionID), Content("hello"), Name("alice"))
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("invalid log line %q: %v", buf.String(), err)
+			}
+			for key, want := range tt.want {
+				value, ok := got[key]
+				if want == nil {
+					if ok {
+						t.Errorf("%s = %v, want omitted", key, value)
+					}
+					continue
+				}
+				if value != want {
+					t.Errorf("%s = %v, want %v", key, value, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	if got := Fingerprint(""); got != "" {
+		t.Errorf("Fingerprint(\"\") = %q, want empty", got)
+	}
+	a, b := Fingerprint("session-a"), Fingerprint("session-b")
+	if a == b {
+		t.Errorf("different secrets got the same fingerprint %q", a)
+	}
+	if a != Fingerprint("session-a") {
+		t.Errorf("fingerprint is not stable")
+	}
+}
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// Prometheus のメトリクス
+// 部屋数・参加者数などのゲージはスクレイプ時に RegisterRoomStats で渡した関数から集計する
+
+const namespace = "onetimechat"
+
+var (
+	// RoomsCreated は作成された部屋の数
+	RoomsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rooms_created_total",
+		Help:      "Number of rooms created.",
+	})
+	// RoomsExpired は期限切れで削除された部屋の数
+	RoomsExpired = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rooms_expired_total",
+		Help:      "Number of rooms removed because they expired.",
+	})
+	// MessagesBroadcast は配信したチャットメッセージの数
+	MessagesBroadcast = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_broadcast_total",
+		Help:      "Number of chat messages broadcast to rooms.",
+	})
+	// Kicks はキックされた参加者の数
+	Kicks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kicks_total",
+		Help:      "Number of participants kicked from rooms.",
+	})
+	// RateLimitHits は制限に引っかかった回数(limit はパスワードの試行回数 "password" またはスローモード "slow_mode")
+	RateLimitHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_hits_total",
+		Help:      "Number of requests rejected by a rate limit.",
+	}, []string{"limit"})
+	// BroadcastFanout は1つのイベントを部屋の全接続に送り終えるまでの時間
+	BroadcastFanout = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "broadcast_fanout_seconds",
+		Help:      "Time taken to fan a message out to every connection in a room.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+	})
+	// HTTPRequestDuration はルートごとのHTTPハンドラーの処理時間
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken by HTTP handlers, by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+)
+
+// RegisterRoomStats は部屋数・参加者数・WebSocket接続数のゲージを登録する
+// スクレイプのたびに stats を1回だけ呼んで全ゲージの値を求める
+func RegisterRoomStats(stats func() model.AdminStats) {
+	prometheus.MustRegister(&roomStatsCollector{stats: stats})
+}
+
+var (
+	activeRoomsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "active_rooms"),
+		"Number of active rooms.", nil, nil)
+	authenticatedClientsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "authenticated_clients"),
+		"Number of participants admitted to rooms.", nil, nil)
+	pendingClientsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "pending_clients"),
+		"Number of clients waiting for approval.", nil, nil)
+	openWebSocketsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "open_websockets"),
+		"Number of open WebSocket connections.", nil, nil)
+)
+
+// roomStatsCollector は部屋の集計をゲージとして公開する
+type roomStatsCollector struct {
+	stats func() model.AdminStats
+}
+
+func (c *roomStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeRoomsDesc
+	ch <- authenticatedClientsDesc
+	ch <- pendingClientsDesc
+	ch <- openWebSocketsDesc
+}
+
+func (c *roomStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats()
+	ch <- prometheus.MustNewConstMetric(activeRoomsDesc, prometheus.GaugeValue, float64(s.Rooms))
+	ch <- prometheus.MustNewConstMetric(authenticatedClientsDesc, prometheus.GaugeValue, float64(s.AuthenticatedClients))
+	ch <- prometheus.MustNewConstMetric(pendingClientsDesc, prometheus.GaugeValue, float64(s.PendingClients))
+	ch <- prometheus.MustNewConstMetric(openWebSocketsDesc, prometheus.GaugeValue, float64(s.WebSocketConnections))
+}
+
+// Middleware はHTTPハンドラーの処理時間をルート("/room/:id" など)ごとに記録する
+// ルートに一致しなかったリクエストは route="unmatched" にまとめる
+// streamingRoutes に指定したルート(WebSocket・SSE)は接続している間ずっとハンドラーが返らず、
+// 処理時間の分布が意味をなさなくなるので記録しない
+func Middleware(streamingRoutes ...string) echo.MiddlewareFunc {
+	skip := make(map[string]bool, len(streamingRoutes))
+	for _, route := range streamingRoutes {
+		skip[route] = true
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				// ステータスコードを確定させるため、ここでエラーハンドラーを呼ぶ
+				c.Error(err)
+			}
+
+			route := c.Path()
+			if skip[route] {
+				return nil
+			}
+			if route == "" {
+				route = "unmatched"
+			}
+			HTTPRequestDuration.WithLabelValues(
+				c.Request().Method,
+				route,
+				strconv.Itoa(c.Response().Status),
+			).Observe(time.Since(start).Seconds())
+			return nil
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// requestCount はHTTPハンドラーのヒストグラムに記録された件数を返す
+func requestCount(t *testing.T, route, code string) uint64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "onetimechat_http_request_duration_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["route"] == route && labels["code"] == code {
+				return m.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return 0
+}
+
+func TestMiddleware(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware("/ws", "/room/:id/events"))
+	e.GET("/room/:id", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	// WebSocket・SSEの代わりに、接続している間ハンドラーが返らないルート
+	streaming := func(c echo.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		return c.NoContent(http.StatusOK)
+	}
+	e.GET("/ws", streaming)
+	e.GET("/room/:id/events", streaming)
+	e.GET("/fail", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusForbidden, "forbidden")
+	})
+
+	tests := []*struct {
+		name   string
+		target string
+		route  string
+		code   string
+		want   uint64 // 増える件数
+	}{
+		{name: "ルートのパターンで集計する", target: "/room/ABCDE", route: "/room/:id", code: "200", want: 1},
+		{name: "エラーのステータスコードを記録する", target: "/fail", route: "/fail", code: "403", want: 1},
+		{name: "WebSocketは記録しない", target: "/ws", route: "/ws", code: "200", want: 0},
+		{name: "SSEは記録しない", target: "/room/ABCDE/events", route: "/room/:id/events", code: "200", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := requestCount(t, tt.route, tt.code)
+			e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if got := requestCount(t, tt.route, tt.code); got != before+tt.want {
+				t.Errorf("count for %s %s = %d, want %d", tt.route, tt.code, got, before+tt.want)
+			}
+		})
+	}
+}
+
+func TestRoomStatsCollector(t *testing.T) {
+	collector := &roomStatsCollector{stats: func() model.AdminStats {
+		return model.AdminStats{Rooms: 2, AuthenticatedClients: 5, PendingClients: 1, WebSocketConnections: 4, SSEConnections: 3}
+	}}
+	want := `
+# HELP onetimechat_active_rooms Number of active rooms.
+# TYPE onetimechat_active_rooms gauge
+onetimechat_active_rooms 2
+# HELP onetimechat_authenticated_clients Number of participants admitted to rooms.
+# TYPE onetimechat_authenticated_clients gauge
+onetimechat_authenticated_clients 5
+# HELP onetimechat_open_websockets Number of open WebSocket connections.
+# TYPE onetimechat_open_websockets gauge
+onetimechat_open_websockets 4
+# HELP onetimechat_pending_clients Number of clients waiting for approval.
+# TYPE onetimechat_pending_clients gauge
+onetimechat_pending_clients 1
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -11,20 +12,155 @@ import (
 type RoomManager struct {
 	Rooms           map[string]*Room // ルームのマップ
 	ExpireSortRooms []*Room          // 期限順に並べたルーム
+	Sessions        *SessionRegistry // 発行済みのセッション
 	Mu              sync.Mutex       // スレッドセーフにするためのミューテックス
 }
 
+// SessionRegistry はサーバー側で発行したセッションを管理する構造体
+type SessionRegistry struct {
+	Sessions map[string]*Session // セッションIDがキー
+	Tokens   map[string]string   // BearerトークンのID -> セッションID
+	Mu       sync.Mutex          // スレッドセーフにするためのミューテックス
+}
+
+// Session は1つの部屋に紐づくセッションを表す構造体
+type Session struct {
+	ID        string    // セッションID
+	RoomID    string    // セッションが有効な部屋のID
+	ClientID  string    // セッションの持ち主のクライアントID
+	IssuedAt  time.Time // 発行日時
+	ExpiresAt time.Time // 有効期限
+	Revoked   bool      // 失効済みかどうか
+}
+
 // Room は個々のチャットルームを表す構造体
 type Room struct {
-	ID                     string     `json:"ID"`             // ルームID
-	Name                   string     `json:"name"`           // ルーム名
-	Owner                  string     `json:"owner"`          // ルームのオーナー
-	OwnerSessionID         string     `json:"ownerSessionID"` // オーナーのセッションID
-	Expires                time.Time  `json:"expires"`        // 有効期限
-	RequiresAuth           bool       `json:"requiresAuth"`   // 認証が必要かどうか
-	UnauthenticatedClients []*Client  // ルームへの接続許可待ちのクライアント
-	AuthenticatedClients   []*Client  //ルームへの接続許可がされているクライアント
-	Mu                     sync.Mutex // スレッドセーフにするためのミューテックス
+	ID                     string             `json:"ID"`                     // ルームID
+	Name                   string             `json:"name"`                   // ルーム名
+	Owner                  string             `json:"owner"`                  // ルームのオーナー
+	OwnerSessionID         string             `json:"ownerSessionID"`         // オーナーのセッションID
+	Expires                time.Time          `json:"expires"`                // 有効期限
+	RequiresAuth           bool               `json:"requiresAuth"`           // 認証が必要かどうか
+	Password               string             `json:"password,omitempty"`     // 作成時に指定するパスワード(保存はしない)
+	PasswordHash           []byte             `json:"-"`                      // パスワードのハッシュ(argon2id)
+	PasswordSalt           []byte             `json:"-"`                      // パスワードのソルト
+	MaxParticipants        int                `json:"maxParticipants"`        // 参加者数の上限(0はサーバーの上限)
+	Locked                 bool               `json:"locked"`                 // 新規の参加を受け付けないかどうか
+	SlowModeSeconds        int                `json:"slowModeSeconds"`        // スローモードの間隔(秒、0は無効)
+	AnnouncementOnly       bool               `json:"announcementOnly"`       // オーナーとモデレーターだけが発言できるかどうか
+	AutoSuccession         bool               `json:"autoSuccession"`         // オーナー不在時に自動で引き継ぐかどうか
+	SuccessionGraceSeconds int                `json:"successionGraceSeconds"` // オーナーが切断してから引き継ぐまでの猶予(秒)
+	SuccessionTimer        *time.Timer        `json:"-"`                      // 自動引き継ぎのタイマー
+	UnauthenticatedClients []*Client          // ルームへの接続許可待ちのクライアント
+	AuthenticatedClients   []*Client          //ルームへの接続許可がされているクライアント
+	Invites                map[string]*Invite `json:"-"` // 招待トークンがキー
+	Bans                   []*Ban             `json:"-"` // 参加禁止の一覧
+	JoinPolicies           []JoinPolicy       `json:"-"` // 参加時に順に評価する自動承認・拒否のポリシー
+	JoinDecisions          []*JoinDecision    `json:"-"` // ポリシーによる判定の記録(直近のもの)
+	Events                 []*Event           `json:"-"` // 再接続時に再送するための直近のイベント
+	LastEventID            int64              `json:"-"` // 最後に発行したイベントID
+	Mu                     sync.Mutex         // スレッドセーフにするためのミューテックス
+}
+
+// RoomSettings はルームの設定変更リクエストを表す構造体
+// ポインタのフィールドは省略された場合に変更しない
+type RoomSettings struct {
+	Name                   string  `json:"name"`                   // ルーム名
+	RequiresAuth           *bool   `json:"requiresAuth"`           // 認証が必要かどうか
+	Password               *string `json:"password"`               // 新しいパスワード(空文字で解除)
+	MaxParticipants        *int    `json:"maxParticipants"`        // 参加者数の上限(0はサーバーの上限)
+	Locked                 *bool   `json:"locked"`                 // 新規の参加を受け付けないかどうか
+	SlowModeSeconds        *int    `json:"slowModeSeconds"`        // スローモードの間隔(秒、0で解除)
+	AnnouncementOnly       *bool   `json:"announcementOnly"`       // オーナーとモデレーターだけが発言できるかどうか
+	AutoSuccession         *bool   `json:"autoSuccession"`         // オーナー不在時に自動で引き継ぐかどうか
+	SuccessionGraceSeconds *int    `json:"successionGraceSeconds"` // オーナーが切断してから引き継ぐまでの猶予(秒)
+}
+
+// JoinRequest はルームへの参加リクエストを表す構造体
+type JoinRequest struct {
+	ClientName  string `json:"client_name"`  // クライアント名
+	Password    string `json:"password"`     // ルームのパスワード
+	InviteToken string `json:"invite_token"` // 招待トークン
+	ClientIP    string `json:"-"`            // リクエスト元のIPアドレス
+	SessionID   string `json:"-"`            // 以前の参加で発行されたセッションID(クッキー、失効済みでもよい)
+	Token       string `json:"-"`            // 以前の参加で発行されたBearerトークン
+}
+
+// Ban は部屋への参加禁止を表す構造体
+// セッションは常に対象となり、IPのフィンガープリントと表示名は任意で対象にする
+type Ban struct {
+	ID        string    `json:"id"`             // 参加禁止ID
+	ClientID  string    `json:"clientID"`       // 対象のクライアントID
+	SessionID string    `json:"-"`              // 対象のセッションID
+	IPHash    string    `json:"-"`              // 対象のIPフィンガープリント(空なら対象外)
+	Name      string    `json:"name,omitempty"` // 対象の表示名(空なら対象外)
+	Reason    string    `json:"reason"`         // 理由
+	CreatedAt time.Time `json:"createdAt"`      // 作成日時
+	ExpiresAt time.Time `json:"expiresAt"`      // 解除日時(Permanentの場合は無視)
+	Permanent bool      `json:"permanent"`      // 無期限かどうか
+	ByIP      bool      `json:"byIP"`           // IPのフィンガープリントも対象にしているかどうか
+}
+
+// Invite はルームへの招待リンクを表す構造体
+type Invite struct {
+	Token       string    `json:"token"`       // 招待トークン
+	MaxUses     int       `json:"maxUses"`     // 使用回数の上限(0は無制限)
+	Uses        int       `json:"uses"`        // 使用された回数
+	ExpiresAt   time.Time `json:"expiresAt"`   // 有効期限
+	PreApproved bool      `json:"preApproved"` // 承認待ちを経ずに参加できるかどうか
+	CreatedBy   string    `json:"createdBy"`   // 作成したクライアントのID
+	CreatedAt   time.Time `json:"createdAt"`   // 作成日時
+}
+
+// JoinOutcome は参加リクエストに対するポリシーの判定結果
+type JoinOutcome string
+
+const (
+	JoinApprove JoinOutcome = "approve" // 承認待ちを経ずに参加させる
+	JoinDeny    JoinOutcome = "deny"    // 参加を拒否する
+	JoinPending JoinOutcome = "pending" // 判定しない(次のポリシー、またはオーナーの承認に任せる)
+)
+
+// JoinCandidate はポリシーが判定に使う参加リクエストの情報
+type JoinCandidate struct {
+	RoomID    string `json:"roomId"`    // ルームID
+	ClientID  string `json:"clientId"`  // 参加しようとしているクライアントのID
+	Name      string `json:"name"`      // 正規化済みの表示名
+	HasInvite bool   `json:"hasInvite"` // 有効な招待トークンを持っているかどうか
+}
+
+// JoinVerdict はポリシーの判定
+type JoinVerdict struct {
+	Outcome   JoinOutcome   // 判定結果
+	Reason    string        // 理由(拒否の場合はクライアントにも伝える)
+	DenyAfter time.Duration // 承認待ちのまま放置された場合に自動で拒否するまでの時間(0なら拒否しない)
+}
+
+// JoinPolicy は参加リクエストを自動で承認・拒否するポリシー
+// Evaluate は部屋のロックの外で呼ばれる
+type JoinPolicy interface {
+	Name() string
+	Spec() JoinPolicySpec
+	Evaluate(ctx context.Context, candidate *JoinCandidate) (JoinVerdict, error)
+}
+
+// JoinPolicySpec は組み込みのポリシーの設定
+type JoinPolicySpec struct {
+	Type           string `json:"type"`                     // name_regex, invite_required, first_n, auto_deny_timeout, http_callback
+	Pattern        string `json:"pattern,omitempty"`        // name_regex: 承認する表示名の正規表現
+	Count          int    `json:"count,omitempty"`          // first_n: 自動承認する人数
+	TimeoutSeconds int    `json:"timeoutSeconds,omitempty"` // auto_deny_timeout: 拒否するまでの秒数, http_callback: 問い合わせのタイムアウト
+	URL            string `json:"url,omitempty"`            // http_callback: 問い合わせ先
+}
+
+// JoinDecision はポリシーによる判定の記録
+type JoinDecision struct {
+	Policy    string      `json:"policy"`           // 判定したポリシー(どのポリシーも判定しなければ "default")
+	ClientID  string      `json:"clientId"`         // 対象のクライアントID
+	Name      string      `json:"name"`             // 対象の表示名
+	Outcome   JoinOutcome `json:"outcome"`          // 判定結果
+	Reason    string      `json:"reason,omitempty"` // 理由
+	DecidedAt time.Time   `json:"decidedAt"`        // 判定日時
 }
 
 // ResponseRoom
@@ -34,31 +170,90 @@ type ResponseRoom struct {
 	Owner                  string            `json:"owner"`                  // ルームのオーナー
 	Expires                time.Time         `json:"expires"`                // 有効期限
 	RequiresAuth           bool              `json:"requiresAuth"`           // 認証が必要かどうか
+	HasPassword            bool              `json:"hasPassword"`            // パスワードが設定されているかどうか
+	MaxParticipants        int               `json:"maxParticipants"`        // 参加者数の上限
+	Locked                 bool              `json:"locked"`                 // 新規の参加を受け付けないかどうか
+	SlowModeSeconds        int               `json:"slowModeSeconds"`        // スローモードの間隔(秒、0は無効)
+	AnnouncementOnly       bool              `json:"announcementOnly"`       // オーナーとモデレーターだけが発言できるかどうか
+	AutoSuccession         bool              `json:"autoSuccession"`         // オーナー不在時に自動で引き継ぐかどうか
+	SuccessionGraceSeconds int               `json:"successionGraceSeconds"` // オーナーが切断してから引き継ぐまでの猶予(秒)
 	UnauthenticatedClients []*ResponseClient `json:"unauthenticatedClients"` // ルームへの接続許可待ちのクライアント
 	AuthenticatedClients   []*ResponseClient `json:"authenticatedClients"`   // ルームへの接続許可がされているクライアント
+	Token                  string            `json:"token,omitempty"`        // 作成者向けのBearerトークン(CreateRoomの応答のみ)
 }
 
+// Role は部屋の中での参加者の役割
+type Role string
+
+const (
+	RoleOwner     Role = "owner"     // 部屋の削除・オーナー譲渡・ロール変更を含むすべての操作ができる
+	RoleModerator Role = "moderator" // 参加の承認・キック・ミュートができる
+	RoleMember    Role = "member"    // メッセージを送信できる
+	RoleViewer    Role = "viewer"    // 閲覧とリアクションのみ
+)
+
 // Client はチャットルームに参加しているユーザーを表す構造体
 type Client struct {
-	Name      string          // クライアント名
-	ClientID  string          // クライアントID
-	SessionID string          // セッションID
-	Ws        *websocket.Conn // WebSocket接続
+	Name      string                 // クライアント名
+	ClientID  string                 // クライアントID
+	SessionID string                 // セッションID
+	Role      Role                   // 部屋の中での役割
+	IPHash    string                 // 参加時のIPアドレスのフィンガープリント(部屋ごとにハッシュ化)
+	JoinedAt  time.Time              // 参加が認められた日時
+	Conns     map[string]*Connection // WebSocket接続(タブ・端末ごと、接続IDがキー)
+
+	LastMessageAt time.Time // 最後にメッセージを送った日時(スローモード用)
+
+	Muted      bool        // ミュートされているかどうか
+	MutedUntil time.Time   // ミュートの期限(ゼロ値は無期限)
+	MuteTimer  *time.Timer // ミュートの期限切れを通知するタイマー
+}
+
+// Connection はクライアントが持つ個々の接続(WebSocketまたはSSE)を表す構造体
+type Connection struct {
+	ID          string          // 接続ID
+	Ws          *websocket.Conn // WebSocket接続(SSEの場合はnil)
+	Send        chan *Event     // SSE接続への送信キュー(WebSocketの場合はnil)
+	Closed      bool            // 接続が閉じられたかどうか
+	ConnectedAt time.Time       // 接続日時
+}
+
+// Event は部屋に配信されたフレームを表す構造体
+// IDはSSEの Last-Event-ID による再開に使用する
+type Event struct {
+	ID       int64  // イベントID(部屋ごとに単調増加)
+	Data     []byte // 配信したJSON
+	AuthOnly bool   // 認証済みクライアントのみに配信したかどうか
 }
 
 // ResponseClient はクライアント情報を表す構造体
 type ResponseClient struct {
 	Name     string `json:"name"`     // クライアント名
 	ClientID string `json:"clientID"` // クライアントID
+	Role     Role   `json:"role"`     // 部屋の中での役割
+	Online   bool   `json:"online"`   // 1つ以上の接続が生きているかどうか
+
+	Muted      bool  `json:"muted"`                // ミュートされているかどうか
+	MutedUntil int64 `json:"mutedUntil,omitempty"` // ミュートの期限(UNIX時間、無期限なら省略)
 }
 
 // Message はチャットメッセージを表す構造体
 type Message struct {
+	ID        int64  `json:"id"`        // イベントID
 	RoomID    string `json:"room_id"`   // ルームID
 	Sentence  string `json:"sentence"`  // メッセージ本文
 	Sender    string `json:"sender"`    // 送信者
 	Timestamp int64  `json:"timestamp"` // タイムスタンプ
 	Type      string `json:"type"`      // メッセージの種類
+
+	// 送信者の情報はセッションから解決したもので、クライアントが名乗った名前ではない
+	SenderID   string `json:"sender_id,omitempty"`   // 送信者のクライアントID
+	SenderRole Role   `json:"sender_role,omitempty"` // 送信時点の送信者のロール
+
+	TargetID int64 `json:"target_id,omitempty"` // Type が "reaction" の場合のリアクション先のメッセージのID
+
+	Code  string `json:"code,omitempty"`  // Type が "error" の場合のエラーの種類
+	Until int64  `json:"until,omitempty"` // ミュートの期限や次に投稿できる時刻(UNIX時間)
 }
 
 // Participant は参加者を表す構造体
@@ -66,4 +261,57 @@ type Participant struct {
 	Name     string `json:"name"`
 	ClientID string `json:"clientid"`
 	IsOwner  bool   `json:"isowner"`
+	Role     Role   `json:"role"`
+	Online   bool   `json:"online"`
+
+	Muted      bool  `json:"muted"`
+	MutedUntil int64 `json:"mutedUntil,omitempty"`
+}
+
+// AdminRoomSummary は運用者向けの部屋の一覧の1件
+type AdminRoomSummary struct {
+	ID                   string    `json:"id"`                   // ルームID
+	Name                 string    `json:"name"`                 // ルーム名
+	Owner                string    `json:"owner"`                // オーナー名
+	Expires              time.Time `json:"expires"`              // 有効期限
+	RequiresAuth         bool      `json:"requiresAuth"`         // 認証が必要かどうか
+	Locked               bool      `json:"locked"`               // 新規の参加を受け付けないかどうか
+	AuthenticatedClients int       `json:"authenticatedClients"` // 参加者数
+	PendingClients       int       `json:"pendingClients"`       // 承認待ちの数
+	OnlineClients        int       `json:"onlineClients"`        // 接続中の参加者数
+	Connections          int       `json:"connections"`          // 接続数(タブ・端末ごと)
+}
+
+// AdminRoomDetail は運用者向けの部屋の詳細
+type AdminRoomDetail struct {
+	AdminRoomSummary
+	Clients []AdminClient `json:"clients"` // 承認待ちを含む参加者
+}
+
+// AdminClient は運用者向けの参加者の情報
+type AdminClient struct {
+	ClientID      string            `json:"clientId"`      // クライアントID
+	Name          string            `json:"name"`          // クライアント名
+	Role          Role              `json:"role"`          // 部屋の中での役割
+	Authenticated bool              `json:"authenticated"` // 参加が認められているかどうか
+	JoinedAt      time.Time         `json:"joinedAt"`      // 参加が認められた日時
+	Muted         bool              `json:"muted"`         // ミュートされているかどうか
+	Connections   []AdminConnection `json:"connections"`   // 接続中の接続
+}
+
+// AdminConnection は運用者向けの接続の情報
+type AdminConnection struct {
+	ID          string    `json:"id"`          // 接続ID
+	Transport   string    `json:"transport"`   // "websocket" または "sse"
+	ConnectedAt time.Time `json:"connectedAt"` // 接続日時
+}
+
+// AdminStats はサーバー全体の集計
+type AdminStats struct {
+	Rooms                int `json:"rooms"`                // 部屋数
+	AuthenticatedClients int `json:"authenticatedClients"` // 参加者数
+	PendingClients       int `json:"pendingClients"`       // 承認待ちの数
+	WebSocketConnections int `json:"websocketConnections"` // WebSocket接続数
+	SSEConnections       int `json:"sseConnections"`       // SSE接続数
+	Sessions             int `json:"sessions"`             // 有効なセッション数
 }
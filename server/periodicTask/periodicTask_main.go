@@ -1,37 +1,54 @@
 package periodicTask
 
 import (
-	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/takaryo1010/OneTimeChat/server/logging"
+	"github.com/takaryo1010/OneTimeChat/server/metrics"
 	"github.com/takaryo1010/OneTimeChat/server/model"
+	"github.com/takaryo1010/OneTimeChat/server/usecase"
 )
 
-func PeriodicTask(rm *model.RoomManager) {
-	ticker := time.NewTicker(5 * time.Minute) // 5分ごとに実行
+// PeriodicTask は interval ごとに期限切れの部屋とセッションを削除する
+// 間隔は毎回 interval から読み直すので、設定の再読み込みで変わった間隔は次の実行から反映される
+func PeriodicTask(rm *model.RoomManager, interval func() time.Duration) {
+	current := interval()
+	ticker := time.NewTicker(current)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		// fmt.Println("定期タスク実行:", time.Now())
 		// 期限切れのルームを削除
 		deleteExpireSortRooms(rm)
+		// 失効済み・期限切れのセッションを削除
+		usecase.PruneSessions(rm.Sessions, time.Now())
 		// ここでDB更新やログ処理などを行う
 
+		if next := interval(); next != current {
+			current = next
+			ticker.Reset(current)
+		}
 	}
 }
 
 func deleteExpireSortRooms(rm *model.RoomManager) {
-	now := time.Now()
-	newRooms := make([]*model.Room, 0, len(rm.ExpireSortRooms)) // ポインタのスライスに変更
+	rm.Mu.Lock()
+	defer rm.Mu.Unlock()
 
+	now := time.Now()
+	// CloseExpiredRoom が ExpireSortRooms から取り除くので、先に期限切れの部屋を集めておく
+	var expired []*model.Room
 	for _, room := range rm.ExpireSortRooms {
-		if room.Expires.After(now) {
-			newRooms = append(newRooms, room) // ポインタ型のまま追加
-		} else {
-			delete(rm.Rooms, room.ID) // 期限切れなら削除
-			fmt.Println("Delete room:", room.ID, "by PeriodicTask")
+		if !room.Expires.After(now) {
+			expired = append(expired, room)
 		}
 	}
 
-	rm.ExpireSortRooms = newRooms // ポインタのスライスを代入
+	for _, room := range expired {
+		// 期限切れなら削除し、残っている接続を切断する
+		if usecase.CloseExpiredRoom(rm, room) {
+			metrics.RoomsExpired.Inc()
+			slog.Info("room expired", logging.KeyRoomID, room.ID)
+		}
+	}
 }
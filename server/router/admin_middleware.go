@@ -0,0 +1,32 @@
+package router
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+
+	"github.com/takaryo1010/OneTimeChat/server/logging"
+)
+
+// adminAuth は Authorization: Bearer <管理用トークン> を確認する
+// 長さの違いから推測されないよう、ハッシュ同士を定数時間で比べる
+func adminAuth(token string, clientIP func(*http.Request) string) echo.MiddlewareFunc {
+	want := sha256.Sum256([]byte(token))
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			scheme, got, found := strings.Cut(c.Request().Header.Get("Authorization"), " ")
+			if !found || !strings.EqualFold(scheme, "Bearer") {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "admin token is required"})
+			}
+			sum := sha256.Sum256([]byte(strings.TrimSpace(got)))
+			if subtle.ConstantTimeCompare(sum[:], want[:]) != 1 {
+				logging.FromContext(c.Request().Context()).Warn("admin API rejected: invalid token", "path", c.Request().URL.Path, logging.IP(clientIP(c.Request())))
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid admin token"})
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package router
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/takaryo1010/OneTimeChat/server/logging"
+)
+
+// 受け付けるリクエストIDの最大長
+const maxRequestIDLength = 64
+
+// requestLogger はリクエストID・ルート・部屋IDを付けたロガーをリクエストのコンテキストに入れる
+// リクエストIDは X-Request-ID ヘッダーがあればそれを使い、なければ生成してレスポンスに付ける
+// 完了したリクエストは Debug で、サーバーエラーになったリクエストは Error で出力する
+func requestLogger(clientIP func(*http.Request) string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+
+			requestID := req.Header.Get(echo.HeaderXRequestID)
+			if requestID == "" || len(requestID) > maxRequestIDLength {
+				requestID = newRequestID()
+			}
+			c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+
+			roomID := c.Param("id")
+			if roomID == "" {
+				roomID = c.QueryParam("room_id")
+			}
+			logger := slog.Default().With(
+				logging.KeyRequestID, requestID,
+				"method", req.Method,
+				"route", c.Path(),
+			)
+			if roomID != "" {
+				logger = logger.With(logging.KeyRoomID, roomID)
+			}
+			c.SetRequest(req.WithContext(logging.WithContext(req.Context(), logger)))
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			status := c.Response().Status
+			level := slog.LevelDebug
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.Log(req.Context(), level, "request completed",
+				"status", status,
+				"duration", time.Since(start),
+				logging.IP(clientIP(req)),
+			)
+			return nil
+		}
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
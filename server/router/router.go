@@ -1,34 +1,63 @@
 package router
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 
-	"github.com/joho/godotenv"
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/takaryo1010/OneTimeChat/server/config"
 	"github.com/takaryo1010/OneTimeChat/server/controller"
+	"github.com/takaryo1010/OneTimeChat/server/logging"
+	"github.com/takaryo1010/OneTimeChat/server/metrics"
+	"github.com/takaryo1010/OneTimeChat/server/usecase"
 )
 
-func NewRouter(mc *controller.MainController) *echo.Echo {
+// NewRouter は設定に従ってルーティングとミドルウェアを組み立てる
+func NewRouter(mc *controller.MainController, cfg *config.Config) *echo.Echo {
 	e := echo.New()
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
 
-	clientURL := os.Getenv("CLIENT_URL")
-	if clientURL == "" {
-		log.Fatal("CLIENT_URL is not set in the environment variables")
-	}
+	mc.Cookies = controller.NewCookieConfig(cfg.Cookie)
+	Apply(mc, cfg)
+
+	// リクエストIDと部屋IDを付けたロガーを各ハンドラーに渡す
+	e.Use(requestLogger(mc.RoomUsecase.ClientIP))
 
 	// CORS設定
+	// 許可されていないオリジンにはCORSヘッダーを付けない(ブラウザがレスポンスを読めない)
+	// 許可されたオリジンはそのまま Access-Control-Allow-Origin に返す
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins:     []string{"http://localhost:3000", "http://192.168.1.9:3000", clientURL}, // フロントエンドのオリジン
-		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete},
+		Skipper: func(c echo.Context) bool {
+			origin := c.Request().Header.Get(echo.HeaderOrigin)
+			if origin == "" {
+				return true
+			}
+			if err := mc.RoomUsecase.AllowedOrigins().Check(origin); err != nil {
+				logging.FromContext(c.Request().Context()).Warn("CORS rejected", "path", c.Request().URL.Path, "error", err)
+				return true
+			}
+			return false
+		},
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
 		AllowCredentials: true,
 	}))
+	// クッキーで認証するのでCSRF対策として Origin を確認する
+	e.Use(csrfOriginCheck(func(origin string) bool {
+		return mc.RoomUsecase.AllowedOrigins().Allowed(origin)
+	}))
+	// ルートごとの処理時間を記録する(WebSocketとSSEは接続時間になるので除く)
+	e.Use(metrics.Middleware("/ws", "/room/:id/events"))
+
+	// Prometheus のメトリクス。metrics_token が設定されていれば Bearer トークンを要求する
+	metrics.RegisterRoomStats(mc.RoomUsecase.AdminStats)
+	var metricsMiddleware []echo.MiddlewareFunc
+	if cfg.MetricsToken != "" {
+		metricsMiddleware = append(metricsMiddleware, adminAuth(cfg.MetricsToken, mc.RoomUsecase.ClientIP))
+	}
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()), metricsMiddleware...)
 
 	// WebSocketエンドポイント
 	e.GET("/ws", mc.WebSocketHandler, func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -41,13 +70,84 @@ func NewRouter(mc *controller.MainController) *echo.Echo {
 	roomGroup.GET("/:id", mc.GetRoom)
 	roomGroup.POST("/:id", mc.JoinRoom)
 	roomGroup.POST("/:id/auth", mc.Authenticate)
+	roomGroup.POST("/:id/auth/bulk", mc.ApproveClients)
+	roomGroup.POST("/:id/deny", mc.DenyClient)
+	roomGroup.POST("/:id/deny/bulk", mc.DenyClients)
+	roomGroup.DELETE("/:id/request", mc.CancelJoinRequest)
 
 	roomGroup.GET("/:id/participants", mc.GetParticipants)
 	roomGroup.PATCH("/:id/settings", mc.UpdateRoomSettings)
 	roomGroup.DELETE("/:id", mc.DeleteRoom)
 	roomGroup.DELETE("/:id/kick", mc.KickParticipant)
+	roomGroup.POST("/:id/mute", mc.MuteClient)
+	roomGroup.DELETE("/:id/mute", mc.UnmuteClient)
+	roomGroup.GET("/:id/bans", mc.ListBans)
+	roomGroup.DELETE("/:id/bans/:banID", mc.LiftBan)
 	roomGroup.DELETE("/:id/leave", mc.LeaveRoom)
+	roomGroup.POST("/:id/owner", mc.TransferOwnership)
+	roomGroup.POST("/:id/role", mc.SetRole)
+
+	// 招待リンク
+	roomGroup.POST("/:id/invites", mc.CreateInvite)
+	roomGroup.GET("/:id/invites", mc.ListInvites)
+	roomGroup.DELETE("/:id/invites/:token", mc.RevokeInvite)
 	roomGroup.GET("/:id/isAuth", mc.IsAuth)
 
+	// 参加ポリシー
+	roomGroup.GET("/:id/policies", mc.GetJoinPolicies)
+	roomGroup.PUT("/:id/policies", mc.SetJoinPolicies)
+	roomGroup.GET("/:id/policies/decisions", mc.ListJoinDecisions)
+
+	// WebSocketが使えない環境向けのSSE受信とHTTP送信
+	roomGroup.GET("/:id/events", mc.Events)
+	roomGroup.POST("/:id/messages", mc.SendMessage)
+	roomGroup.POST("/:id/reactions", mc.SendReaction)
+
+	// 運用者向けAPI。admin_token が設定されている場合のみ有効にする
+	if cfg.AdminToken != "" {
+		adminGroup := e.Group("/admin", adminAuth(cfg.AdminToken, mc.RoomUsecase.ClientIP))
+		adminGroup.GET("/rooms", mc.AdminListRooms)
+		adminGroup.GET("/rooms/:id", mc.AdminGetRoom)
+		adminGroup.DELETE("/rooms/:id", mc.AdminCloseRoom)
+		adminGroup.DELETE("/rooms/:id/clients/:clientID", mc.AdminDisconnectClient)
+		adminGroup.GET("/stats", mc.AdminStats)
+	} else {
+		slog.Info("admin_token is not set, the admin API is disabled")
+	}
+
 	return e
 }
+
+// Apply は再読み込みできる設定(ログ・オリジン・プロキシ・上限)を反映する
+// 起動時と SIGHUP による再読み込みのたびに呼ぶ
+func Apply(mc *controller.MainController, cfg *config.Config) {
+	slog.SetDefault(logging.New(os.Stderr, logging.Config{
+		Level:   cfg.Log.SlogLevel(),
+		JSON:    cfg.Log.Format == "json",
+		Redact:  cfg.Log.Redact,
+		Privacy: cfg.Log.Privacy,
+	}))
+
+	// CORS・CSRF対策・WebSocketで同じ設定を使う
+	origins, err := config.ParseAllowedOrigins(cfg.Origins())
+	if err != nil {
+		// Validate で確認済みなのでここには来ない
+		slog.Error("invalid allowed origins, keeping the current ones", "error", err)
+	} else {
+		mc.RoomUsecase.SetAllowedOrigins(origins)
+	}
+
+	proxies, err := config.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		slog.Error("invalid trusted proxies, keeping the current ones", "error", err)
+	} else {
+		mc.RoomUsecase.SetTrustedProxies(proxies)
+	}
+
+	mc.RoomUsecase.SetLimits(usecase.Limits{
+		MaxParticipantsCeiling: cfg.Rooms.MaxParticipants,
+		MinRoomTTL:             cfg.Rooms.MinTTL.Duration,
+		MaxRoomTTL:             cfg.Rooms.MaxTTL.Duration,
+		JoinCallbackAllowlist:  cfg.JoinCallbackAllowlist,
+	})
+}
@@ -0,0 +1,177 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/takaryo1010/OneTimeChat/server/config"
+	"github.com/takaryo1010/OneTimeChat/server/controller"
+	"github.com/takaryo1010/OneTimeChat/server/usecase"
+)
+
+var (
+	testRouterOnce sync.Once
+	testRouter     *echo.Echo
+	testController *controller.MainController
+)
+
+// newTestRouter は既定の設定でルーターを作る
+// メトリクスの登録は1プロセスで1回しかできないので、テスト全体で同じルーターを使う
+func newTestRouter() (*echo.Echo, *controller.MainController) {
+	testRouterOnce.Do(func() {
+		testController = &controller.MainController{RoomUsecase: usecase.NewRoomUsecase()}
+		testRouter = NewRouter(testController, config.Default())
+	})
+	return testRouter, testController
+}
+
+// createRoomRequest は部屋を作るリクエストを作る
+func createRoomRequest() *http.Request {
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	req := httptest.NewRequest(http.MethodPost, "/room", strings.NewReader(`{"name":"room","owner":"alice","expires":"`+expires+`"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	return req
+}
+
+func TestCSRFOriginCheck(t *testing.T) {
+	e, _ := newTestRouter()
+
+	tests := []*struct {
+		name       string
+		header     map[string]string
+		wantStatus int
+	}{
+		{
+			name:       "cross origin POST with a cookie is rejected",
+			header:     map[string]string{"Origin": "https://evil.example", "Cookie": "session_id_ABC=x"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "cross origin Referer is rejected",
+			header:     map[string]string{"Referer": "https://evil.example/page", "Cookie": "session_id_ABC=x"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "cookie without Origin and Referer is rejected",
+			header:     map[string]string{"Cookie": "session_id_ABC=x"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "same origin POST passes",
+			header:     map[string]string{"Origin": "http://localhost:3000", "Cookie": "session_id_ABC=x"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Bearer token skips the check",
+			header:     map[string]string{"Origin": "https://evil.example", "Authorization": "Bearer token"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "non browser request without cookies passes",
+			header:     map[string]string{},
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := createRoomRequest()
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestSessionCookieAttributes(t *testing.T) {
+	e, mc := newTestRouter()
+	defaults := mc.Cookies
+	defer func() { mc.Cookies = defaults }()
+
+	tests := []*struct {
+		name       string
+		cookies    controller.CookieConfig
+		wantSecure bool
+	}{
+		{name: "defaults", cookies: defaults, wantSecure: true},
+		{name: "secure disabled for plain HTTP", cookies: controller.NewCookieConfig(config.CookieSettings{HTTPOnly: true, Secure: false, SameSite: "lax"}), wantSecure: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc.Cookies = tt.cookies
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, createRoomRequest())
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, http.StatusOK, rec.Body)
+			}
+
+			var session *http.Cookie
+			for _, c := range (&http.Response{Header: rec.Header()}).Cookies() {
+				if strings.HasPrefix(c.Name, "session_id_") {
+					session = c
+				}
+			}
+			if session == nil {
+				t.Fatalf("Set-Cookie = %v, want a session_id_<roomID> cookie", rec.Header()["Set-Cookie"])
+			}
+			if !session.HttpOnly {
+				t.Errorf("session cookie should be HttpOnly")
+			}
+			if session.SameSite != http.SameSiteLaxMode {
+				t.Errorf("session cookie SameSite = %v, want Lax", session.SameSite)
+			}
+			if session.Secure != tt.wantSecure {
+				t.Errorf("session cookie Secure = %v, want %v", session.Secure, tt.wantSecure)
+			}
+		})
+	}
+}
+
+func TestValidationErrorStatus(t *testing.T) {
+	e, _ := newTestRouter()
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, createRoomRequest())
+	var room struct {
+		ID string `json:"ID"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &room); err != nil || room.ID == "" {
+		t.Fatalf("CreateRoom response = %s, %v", rec.Body, err)
+	}
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []*struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{name: "empty room name", method: http.MethodPost, path: "/room", body: `{"name":" ","owner":"alice","expires":"` + expires + `"}`},
+		{name: "owner name too long", method: http.MethodPost, path: "/room", body: `{"name":"room","owner":"` + strings.Repeat("a", 100) + `","expires":"` + expires + `"}`},
+		{name: "control character in room name", method: http.MethodPost, path: "/room", body: `{"name":"ro\u0007om","owner":"alice","expires":"` + expires + `"}`},
+		{name: "expires in the past", method: http.MethodPost, path: "/room", body: `{"name":"room","owner":"alice","expires":"2000-01-01T00:00:00Z"}`},
+		{name: "negative maxParticipants", method: http.MethodPost, path: "/room", body: `{"name":"room","owner":"alice","expires":"` + expires + `","maxParticipants":-1}`},
+		{name: "empty client name", method: http.MethodPost, path: "/room/" + room.ID, body: `{"client_name":""}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, http.StatusBadRequest, rec.Body)
+			}
+		})
+	}
+}
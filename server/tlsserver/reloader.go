@@ -0,0 +1,109 @@
+package tlsserver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPollInterval は証明書ファイルの変更を確認する間隔の既定値
+const DefaultPollInterval = 10 * time.Second
+
+// CertReloader は証明書と鍵のファイルを監視し、書き換えられたら読み直す
+// 読み直しに失敗した場合は前の証明書を使い続ける
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	cert atomic.Pointer[tls.Certificate]
+
+	mu    sync.Mutex // stamp を守る
+	stamp fileStamp  // 最後に読み込んだときのファイルの状態
+}
+
+// fileStamp は変更の検出に使うファイルの更新日時とサイズ
+type fileStamp struct {
+	certMod, keyMod   time.Time
+	certSize, keySize int64
+}
+
+// NewCertReloader は証明書と鍵を読み込む。読み込めなければエラーを返す
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate は tls.Config.GetCertificate に渡す関数
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// NotAfter は現在の証明書の有効期限を返す
+func (r *CertReloader) NotAfter() time.Time {
+	if leaf := r.cert.Load().Leaf; leaf != nil {
+		return leaf.NotAfter
+	}
+	return time.Time{}
+}
+
+// Watch は interval ごとにファイルの更新日時とサイズを確認し、変わっていれば読み直す
+// 証明書の更新ツールがファイルを置き換える途中で読むと鍵と証明書が合わないことがあるが、
+// その場合は前の証明書のまま次の確認で読み直す
+func (r *CertReloader) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		reloaded, err := r.reloadIfChanged()
+		if err != nil {
+			slog.Error("TLS certificate reload failed, keeping the current certificate", "cert_file", r.certFile, "error", err)
+			continue
+		}
+		if reloaded {
+			slog.Info("TLS certificate reloaded", "cert_file", r.certFile, "not_after", r.NotAfter())
+		}
+	}
+}
+
+// reloadIfChanged はファイルが前回から変わっていれば読み直し、読み直したかどうかを返す
+func (r *CertReloader) reloadIfChanged() (bool, error) {
+	stamp, err := statFiles(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cert.Load() != nil && stamp == r.stamp {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("load key pair: %w", err)
+	}
+	r.cert.Store(&cert)
+	r.stamp = stamp
+	return true, nil
+}
+
+func statFiles(certFile, keyFile string) (fileStamp, error) {
+	cert, err := os.Stat(certFile)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	key, err := os.Stat(keyFile)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{
+		certMod: cert.ModTime(), keyMod: key.ModTime(),
+		certSize: cert.Size(), keySize: key.Size(),
+	}, nil
+}
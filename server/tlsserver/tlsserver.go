@@ -0,0 +1,41 @@
+package tlsserver
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+)
+
+// Config はHTTPSの待ち受けに使う tls.Config を作る
+// 証明書は reloader から接続のたびに取り出すので、読み直した証明書は新しい接続から使われる
+func Config(reloader *CertReloader, minVersion uint16) *tls.Config {
+	return &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: reloader.GetCertificate,
+		// WebSocketはHTTP/1.1で接続してくるので、どちらも受け付ける
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+}
+
+// RedirectHandler はHTTPのリクエストを同じホスト・パスのHTTPSにリダイレクトする
+// httpsAddr はHTTPSで待ち受けているアドレスで、443以外ならポートをリダイレクト先に付ける
+// メソッドと本文を保ったまま転送させるため 308 を返す
+func RedirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			http.Error(w, "missing Host header", http.StatusBadRequest)
+			return
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if net.ParseIP(host) != nil && net.ParseIP(host).To4() == nil {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
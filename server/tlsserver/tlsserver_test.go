@@ -0,0 +1,154 @@
+package tlsserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeKeyPair は commonName の自己署名証明書と鍵を書き出す
+// 更新日時の違いで変更を検出できるよう、書き出したファイルの更新日時を modTime にする
+func writeKeyPair(t *testing.T, certFile, keyFile, commonName string, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []struct {
+		path  string
+		block *pem.Block
+	}{
+		{certFile, &pem.Block{Type: "CERTIFICATE", Bytes: der}},
+		{keyFile, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}},
+	} {
+		if err := os.WriteFile(f.path, pem.EncodeToMemory(f.block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(f.path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func commonName(t *testing.T, r *CertReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	base := time.Now().Add(-time.Hour)
+	writeKeyPair(t, certFile, keyFile, "first", base)
+
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewCertReloader() error = %v", err)
+	}
+	if got := commonName(t, r); got != "first" {
+		t.Fatalf("certificate = %q, want first", got)
+	}
+
+	tests := []*struct {
+		name         string
+		change       func()
+		wantReloaded bool
+		wantErr      bool
+		wantName     string
+	}{
+		{
+			name:     "変更がなければ読み直さない",
+			change:   func() {},
+			wantName: "first",
+		},
+		{
+			name:         "書き換えられた証明書を読み直す",
+			change:       func() { writeKeyPair(t, certFile, keyFile, "second", base.Add(time.Minute)) },
+			wantReloaded: true,
+			wantName:     "second",
+		},
+		{
+			name: "壊れた証明書では前の証明書を使い続ける",
+			change: func() {
+				if err := os.WriteFile(certFile, []byte("not a certificate"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr:  true,
+			wantName: "second",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			reloaded, err := r.reloadIfChanged()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reloadIfChanged() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if reloaded != tt.wantReloaded {
+				t.Errorf("reloaded = %v, want %v", reloaded, tt.wantReloaded)
+			}
+			if got := commonName(t, r); got != tt.wantName {
+				t.Errorf("certificate = %q, want %q", got, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []*struct {
+		name      string
+		httpsAddr string
+		target    string
+		want      string
+	}{
+		{name: "443ではポートを付けない", httpsAddr: ":443", target: "http://chat.example.com/room/ABCDE?invite=x", want: "https://chat.example.com/room/ABCDE?invite=x"},
+		{name: "HTTPのポートは外す", httpsAddr: ":443", target: "http://chat.example.com:80/ws", want: "https://chat.example.com/ws"},
+		{name: "443以外ならポートを付ける", httpsAddr: "0.0.0.0:8443", target: "http://localhost:8080/room", want: "https://localhost:8443/room"},
+		{name: "IPv6", httpsAddr: ":443", target: "http://[::1]:80/", want: "https://[::1]/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			RedirectHandler(tt.httpsAddr).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.target, nil))
+			if rec.Code != http.StatusPermanentRedirect {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusPermanentRedirect)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package usecase
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// 運用者向けの操作はセッションではなく管理用トークンで認証する(router で確認する)
+// そのため、ここでは部屋の権限は確認しない
+
+// AdminListRooms はすべての部屋を有効期限の早い順に返す
+func (uc *RoomUsecase) AdminListRooms() []model.AdminRoomSummary {
+	uc.RoomManager.Mu.Lock()
+	defer uc.RoomManager.Mu.Unlock()
+
+	rooms := make([]model.AdminRoomSummary, 0, len(uc.RoomManager.Rooms))
+	for _, room := range uc.RoomManager.Rooms {
+		room.Mu.Lock()
+		rooms = append(rooms, adminRoomSummary(room))
+		room.Mu.Unlock()
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].Expires.Before(rooms[j].Expires)
+	})
+	return rooms
+}
+
+// AdminGetRoom は部屋の参加者と接続状況を返す
+func (uc *RoomUsecase) AdminGetRoom(roomID string) (*model.AdminRoomDetail, error) {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return nil, errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	detail := &model.AdminRoomDetail{
+		AdminRoomSummary: adminRoomSummary(room),
+		Clients:          []model.AdminClient{},
+	}
+	now := time.Now()
+	for _, authenticated := range []bool{true, false} {
+		clients := room.UnauthenticatedClients
+		if authenticated {
+			clients = room.AuthenticatedClients
+		}
+		for _, client := range clients {
+			c := model.AdminClient{
+				ClientID:      client.ClientID,
+				Name:          client.Name,
+				Role:          client.Role,
+				Authenticated: authenticated,
+				JoinedAt:      client.JoinedAt,
+				Muted:         isMuted(client, now),
+				Connections:   []model.AdminConnection{},
+			}
+			for _, conn := range client.Conns {
+				c.Connections = append(c.Connections, model.AdminConnection{
+					ID:          conn.ID,
+					Transport:   connTransport(conn),
+					ConnectedAt: conn.ConnectedAt,
+				})
+			}
+			sort.Slice(c.Connections, func(i, j int) bool {
+				return c.Connections[i].ConnectedAt.Before(c.Connections[j].ConnectedAt)
+			})
+			detail.Clients = append(detail.Clients, c)
+		}
+	}
+	return detail, nil
+}
+
+// AdminCloseRoom は部屋を強制的に閉じる
+// 参加者には room_closed イベントで理由を知らせてから接続を閉じる
+func (uc *RoomUsecase) AdminCloseRoom(roomID, reason string) error {
+	if reason == "" {
+		reason = "closed by the server operator"
+	}
+
+	uc.RoomManager.Mu.Lock()
+	defer uc.RoomManager.Mu.Unlock()
+
+	room, exists := uc.RoomManager.Rooms[roomID]
+	if !exists {
+		return errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	closeRoom(uc.RoomManager, room, reason)
+	return nil
+}
+
+// AdminDisconnectClient はクライアントの接続をすべて閉じる
+// キックとは異なりセッションは残るので、クライアントは再接続できる
+func (uc *RoomUsecase) AdminDisconnectClient(roomID, clientID, reason string) error {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	for _, clients := range [][]*model.Client{room.AuthenticatedClients, room.UnauthenticatedClients} {
+		for _, client := range clients {
+			if client.ClientID != clientID {
+				continue
+			}
+			if !isOnline(client) {
+				return errors.New("client is not connected")
+			}
+			if reason != "" {
+				if event := directEvent(room, &model.Message{
+					RoomID:    room.ID,
+					Sentence:  reason,
+					Timestamp: time.Now().Unix(),
+					Type:      "disconnected",
+				}); event != nil {
+					sendToClient(client, event)
+				}
+			}
+			disconnectClient(client)
+			return nil
+		}
+	}
+	return errors.New("client not found in the room")
+}
+
+// AdminStats はサーバー全体の部屋数・参加者数・接続数を集計する
+func (uc *RoomUsecase) AdminStats() model.AdminStats {
+	var stats model.AdminStats
+
+	uc.RoomManager.Mu.Lock()
+	stats.Rooms = len(uc.RoomManager.Rooms)
+	for _, room := range uc.RoomManager.Rooms {
+		room.Mu.Lock()
+		stats.AuthenticatedClients += len(room.AuthenticatedClients)
+		stats.PendingClients += len(room.UnauthenticatedClients)
+		for _, clients := range [][]*model.Client{room.AuthenticatedClients, room.UnauthenticatedClients} {
+			for _, client := range clients {
+				for _, conn := range client.Conns {
+					if conn.Ws != nil {
+						stats.WebSocketConnections++
+					} else {
+						stats.SSEConnections++
+					}
+				}
+			}
+		}
+		room.Mu.Unlock()
+	}
+	uc.RoomManager.Mu.Unlock()
+
+	reg := uc.RoomManager.Sessions
+	reg.Mu.Lock()
+	now := time.Now()
+	for _, session := range reg.Sessions {
+		if !session.Revoked && now.Before(session.ExpiresAt) {
+			stats.Sessions++
+		}
+	}
+	reg.Mu.Unlock()
+	return stats
+}
+
+// adminRoomSummary は部屋の一覧用の集計を作る
+// 呼び出し側で room.Mu をロックしておくこと
+func adminRoomSummary(room *model.Room) model.AdminRoomSummary {
+	summary := model.AdminRoomSummary{
+		ID:                   room.ID,
+		Name:                 room.Name,
+		Owner:                room.Owner,
+		Expires:              room.Expires,
+		RequiresAuth:         room.RequiresAuth,
+		Locked:               room.Locked,
+		AuthenticatedClients: len(room.AuthenticatedClients),
+		PendingClients:       len(room.UnauthenticatedClients),
+	}
+	for _, clients := range [][]*model.Client{room.AuthenticatedClients, room.UnauthenticatedClients} {
+		for _, client := range clients {
+			if isOnline(client) {
+				summary.OnlineClients++
+			}
+			summary.Connections += len(client.Conns)
+		}
+	}
+	return summary
+}
+
+func connTransport(conn *model.Connection) string {
+	if conn.Ws != nil {
+		return "websocket"
+	}
+	return "sse"
+}
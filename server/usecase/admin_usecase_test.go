@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+func TestAdminOperations(t *testing.T) {
+	uc := NewRoomUsecase()
+	open, _, err := uc.CreateRoom(&model.Room{Name: "open", Owner: "owner", Expires: time.Now().Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	gated, _, err := uc.CreateRoom(&model.Room{Name: "gated", Owner: "owner", Expires: time.Now().Add(time.Hour), RequiresAuth: true})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	if _, err := uc.JoinRoom(context.Background(), open.ID, &model.JoinRequest{ClientName: "member"}); err != nil {
+		t.Fatalf("JoinRoom() error = %v", err)
+	}
+	guestSessionID, err := uc.JoinRoom(context.Background(), gated.ID, &model.JoinRequest{ClientName: "guest"})
+	if err != nil {
+		t.Fatalf("JoinRoom() error = %v", err)
+	}
+
+	rooms := uc.AdminListRooms()
+	if len(rooms) != 2 || rooms[0].ID != gated.ID || rooms[0].PendingClients != 1 || rooms[1].AuthenticatedClients != 2 {
+		t.Errorf("AdminListRooms() = %+v, want gated room first with 1 pending, open room with 2 participants", rooms)
+	}
+
+	detail, err := uc.AdminGetRoom(gated.ID)
+	if err != nil {
+		t.Fatalf("AdminGetRoom() error = %v", err)
+	}
+	if len(detail.Clients) != 2 || detail.Clients[1].Authenticated {
+		t.Errorf("AdminGetRoom().Clients = %+v, want owner and one pending guest", detail.Clients)
+	}
+
+	want := model.AdminStats{Rooms: 2, AuthenticatedClients: 3, PendingClients: 1, Sessions: 4}
+	if got := uc.AdminStats(); got != want {
+		t.Errorf("AdminStats() = %+v, want %+v", got, want)
+	}
+
+	if err := uc.AdminCloseRoom(gated.ID, "maintenance"); err != nil {
+		t.Fatalf("AdminCloseRoom() error = %v", err)
+	}
+	if _, err := uc.GetRoomByID(gated.ID); err == nil {
+		t.Errorf("GetRoomByID() after AdminCloseRoom should fail")
+	}
+	if _, err := uc.ValidateSession(gated.ID, guestSessionID); err == nil {
+		t.Errorf("ValidateSession() after AdminCloseRoom should fail")
+	}
+	if got := uc.AdminStats(); got.Rooms != 1 || got.Sessions != 2 {
+		t.Errorf("AdminStats() after close = %+v, want 1 room and 2 sessions", got)
+	}
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// ErrAnnouncementOnly はアナウンス専用の部屋でオーナー・モデレーター以外が発言しようとした場合のエラー
+var ErrAnnouncementOnly = errors.New("only owners and moderators can post in this room")
+
+// リアクションの最大文字数(絵文字の結合文字列を考慮して少し余裕を持たせる)
+const maxReactionLength = 16
+
+// checkAnnouncementOnly はアナウンス専用の部屋でクライアントが発言できるかを確認する
+// 呼び出し側で room.Mu をロックしておくこと
+func checkAnnouncementOnly(room *model.Room, sender *model.Client) error {
+	if !room.AnnouncementOnly || roleRanks[sender.Role] >= roleRanks[model.RoleModerator] {
+		return nil
+	}
+	return &ChatError{Code: "announcement_only", Err: ErrAnnouncementOnly}
+}
+
+// postReaction はメッセージへのリアクションを認証済みクライアントに配信する
+// アナウンス専用の部屋やスローモード中でもリアクションはできる
+// 呼び出し側で room.Mu をロックしておくこと
+func postReaction(room *model.Room, sessionID, connID string, targetID int64, reaction string) error {
+	sender, err := authorize(room, sessionID, PermReact)
+	if err != nil {
+		return err
+	}
+	if isMuted(sender, time.Now()) {
+		return &ChatError{Code: "muted", Until: sender.MutedUntil, Err: ErrMuted}
+	}
+	if reaction == "" || utf8.RuneCountInString(reaction) > maxReactionLength {
+		return &ChatError{Code: "invalid_reaction", Err: errors.New("reaction must be between 1 and 16 characters")}
+	}
+	if targetID <= 0 || targetID > room.LastEventID {
+		return &ChatError{Code: "invalid_reaction", Err: errors.New("message to react to not found")}
+	}
+
+	event := recordEvent(room, &model.Message{
+		RoomID:    room.ID,
+		Sentence:  reaction,
+		Sender:    sender.Name,
+		Timestamp: time.Now().Unix(),
+		Type:      "reaction",
+
+		SenderID:   sender.ClientID,
+		SenderRole: sender.Role,
+		TargetID:   targetID,
+	}, true)
+	if event == nil {
+		return errors.New("failed to encode reaction")
+	}
+	for _, client := range room.AuthenticatedClients {
+		sendToClientExcept(client, event, connID)
+	}
+	return nil
+}
+
+// SendReaction はHTTP経由でリアクションを送信する
+// WebSocketで "reaction" フレームを送った場合と同じように配信される
+func (uc *RoomUsecase) SendReaction(roomID, sessionID string, targetID int64, reaction string) error {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	return postReaction(room, sessionID, "", targetID, reaction)
+}
@@ -0,0 +1,66 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+func TestAnnouncementOnlyRoom(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "incident", Owner: "owner", Expires: time.Now().Add(time.Hour), AnnouncementOnly: true})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	if !room.AnnouncementOnly {
+		t.Fatalf("ResponseRoom.AnnouncementOnly = false, want true")
+	}
+	memberSessionID, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "member"})
+	if err != nil {
+		t.Fatalf("JoinRoom() error = %v", err)
+	}
+	viewerSessionID, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "viewer"})
+	if err != nil {
+		t.Fatalf("JoinRoom() error = %v", err)
+	}
+	participants, _, err := uc.GetParticipants(room.ID)
+	if err != nil {
+		t.Fatalf("GetParticipants() error = %v", err)
+	}
+	if err := uc.SetRole(room.ID, ownerSessionID, participants[2].ClientID, model.RoleViewer); err != nil {
+		t.Fatalf("SetRole() error = %v", err)
+	}
+
+	if err := uc.SendMessage(room.ID, ownerSessionID, "we are investigating"); err != nil {
+		t.Fatalf("SendMessage() by owner error = %v", err)
+	}
+	messageID := uc.RoomManager.Rooms[room.ID].LastEventID
+
+	tests := []*struct {
+		name    string
+		send    func() error
+		wantErr error
+	}{
+		{name: "member message", send: func() error { return uc.SendMessage(room.ID, memberSessionID, "any update?") }, wantErr: ErrAnnouncementOnly},
+		{name: "member reaction", send: func() error { return uc.SendReaction(room.ID, memberSessionID, messageID, "👍") }, wantErr: nil},
+		{name: "viewer reaction", send: func() error { return uc.SendReaction(room.ID, viewerSessionID, messageID, "👀") }, wantErr: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.send(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	off := false
+	if _, err := uc.UpdateRoomSettings(room.ID, &model.RoomSettings{Name: room.Name, AnnouncementOnly: &off}, ownerSessionID); err != nil {
+		t.Fatalf("UpdateRoomSettings() error = %v", err)
+	}
+	if err := uc.SendMessage(room.ID, memberSessionID, "any update?"); err != nil {
+		t.Errorf("SendMessage() after turning off announcement-only error = %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+	"github.com/takaryo1010/OneTimeChat/server/validator"
+)
+
+// ErrBanned は参加禁止中のクライアントが参加・接続しようとした場合のエラー
+var ErrBanned = errors.New("you are banned from this room")
+
+// BanOptions はキック時に参加禁止にする場合の設定
+type BanOptions struct {
+	Duration time.Duration // 参加禁止の期間(0なら無期限)
+	ByIP     bool          // IPのフィンガープリントも対象にする
+	ByName   bool          // 表示名も対象にする
+	Reason   string        // 理由
+}
+
+// ipFingerprint はIPアドレスを部屋ごとのフィンガープリントに変換する
+// 生のIPアドレスは保存せず、部屋をまたいだ突き合わせもできないようにする
+func ipFingerprint(roomID, ip string) string {
+	if ip == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(roomID + "\x00" + ip))
+	return hex.EncodeToString(sum[:16])
+}
+
+// addBan はクライアントを参加禁止にする
+// 呼び出し側で room.Mu をロックしておくこと
+func addBan(room *model.Room, client *model.Client, opts *BanOptions, now time.Time) *model.Ban {
+	ban := &model.Ban{
+		ID:        secureRandomString("abcdefghijklmnopqrstuvwxyz0123456789", 12),
+		ClientID:  client.ClientID,
+		SessionID: client.SessionID,
+		Reason:    opts.Reason,
+		CreatedAt: now,
+		Permanent: opts.Duration <= 0,
+	}
+	if !ban.Permanent {
+		ban.ExpiresAt = now.Add(opts.Duration)
+	}
+	if opts.ByIP && client.IPHash != "" {
+		ban.IPHash = client.IPHash
+		ban.ByIP = true
+	}
+	if opts.ByName {
+		ban.Name = client.Name
+	}
+	room.Bans = append(room.Bans, ban)
+	return ban
+}
+
+// banTarget は参加禁止に一致するかを調べる対象。空の項目は照合しない
+type banTarget struct {
+	SessionID string // セッションID(キックで失効した以前の参加のものを含む)
+	ClientID  string // クライアントID(以前の参加で発行されたBearerトークンから取り出したもの)
+	IPHash    string // IPアドレスのフィンガープリント
+	Name      string // 表示名
+}
+
+// activeBan はセッション・クライアントID・IPフィンガープリント・表示名(紛らわしいものを含む)のいずれかに一致する有効な参加禁止を返す
+// 期限切れの参加禁止はここで取り除く
+// 呼び出し側で room.Mu をロックしておくこと
+func activeBan(room *model.Room, target banTarget, now time.Time) *model.Ban {
+	kept := room.Bans[:0]
+	var found *model.Ban
+	for _, ban := range room.Bans {
+		if !ban.Permanent && !now.Before(ban.ExpiresAt) {
+			continue
+		}
+		kept = append(kept, ban)
+		if found != nil {
+			continue
+		}
+		switch {
+		case target.SessionID != "" && ban.SessionID == target.SessionID:
+			found = ban
+		case target.ClientID != "" && ban.ClientID == target.ClientID:
+			found = ban
+		case target.IPHash != "" && ban.IPHash == target.IPHash:
+			found = ban
+		case target.Name != "" && ban.Name != "" && validator.Confusable(ban.Name, target.Name):
+			found = ban
+		}
+	}
+	room.Bans = kept
+	return found
+}
+
+// ListBans は有効な参加禁止の一覧を返す(オーナー・モデレーター用)
+func (uc *RoomUsecase) ListBans(roomID, sessionID string) ([]model.Ban, error) {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return nil, errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if _, err := authorize(room, sessionID, PermKick); err != nil {
+		return nil, err
+	}
+
+	// 期限切れを取り除いてから一覧にする
+	activeBan(room, banTarget{}, time.Now())
+	bans := make([]model.Ban, 0, len(room.Bans))
+	for _, ban := range room.Bans {
+		bans = append(bans, *ban)
+	}
+	return bans, nil
+}
+
+// LiftBan は参加禁止を解除する(オーナー・モデレーター用)
+func (uc *RoomUsecase) LiftBan(roomID, sessionID, banID string) error {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if _, err := authorize(room, sessionID, PermKick); err != nil {
+		return err
+	}
+
+	for i, ban := range room.Bans {
+		if ban.ID == banID {
+			room.Bans = append(room.Bans[:i], room.Bans[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("ban not found")
+}
@@ -0,0 +1,120 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+func TestKickWithBan(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	if _, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "spammer", ClientIP: "192.0.2.1"}); err != nil {
+		t.Fatalf("JoinRoom() error = %v", err)
+	}
+	participants, _, err := uc.GetParticipants(room.ID)
+	if err != nil {
+		t.Fatalf("GetParticipants() error = %v", err)
+	}
+	spammerID := participants[1].ClientID
+
+	err = uc.KickParticipant(room.ID, spammerID, ownerSessionID, &BanOptions{ByIP: true, ByName: true})
+	if err != nil {
+		t.Fatalf("KickParticipant() error = %v", err)
+	}
+
+	tests := []*struct {
+		name    string
+		req     *model.JoinRequest
+		wantErr error
+	}{
+		{name: "same IP", req: &model.JoinRequest{ClientName: "someone", ClientIP: "192.0.2.1"}, wantErr: ErrBanned},
+		{name: "same name", req: &model.JoinRequest{ClientName: "SPAMMER", ClientIP: "192.0.2.2"}, wantErr: ErrBanned},
+		{name: "different IP and name", req: &model.JoinRequest{ClientName: "someone", ClientIP: "192.0.2.3"}, wantErr: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := uc.JoinRoom(context.Background(), room.ID, tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("JoinRoom() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	bans, err := uc.ListBans(room.ID, ownerSessionID)
+	if err != nil || len(bans) != 1 {
+		t.Fatalf("ListBans() = %v, %v, want 1 ban", bans, err)
+	}
+	if err := uc.LiftBan(room.ID, ownerSessionID, bans[0].ID); err != nil {
+		t.Fatalf("LiftBan() error = %v", err)
+	}
+	if _, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "spammer", ClientIP: "192.0.2.1"}); err != nil {
+		t.Errorf("JoinRoom() after LiftBan() error = %v", err)
+	}
+}
+
+func Test_activeBanExpires(t *testing.T) {
+	now := time.Now()
+	room := &model.Room{
+		Bans: []*model.Ban{{ID: "timed", SessionID: "s", ExpiresAt: now.Add(time.Minute)}},
+	}
+	if activeBan(room, banTarget{SessionID: "s"}, now) == nil {
+		t.Errorf("activeBan() should find the ban before it expires")
+	}
+	if activeBan(room, banTarget{SessionID: "s"}, now.Add(2*time.Minute)) != nil {
+		t.Errorf("activeBan() should not find the ban after it expires")
+	}
+	if len(room.Bans) != 0 {
+		t.Errorf("expired ban should be removed, got %v", room.Bans)
+	}
+}
+
+func TestKickWithSessionBan(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	spammerSessionID, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "spammer", ClientIP: "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("JoinRoom() error = %v", err)
+	}
+	spammerToken, err := uc.IssueToken(room.ID, spammerSessionID)
+	if err != nil {
+		t.Fatalf("IssueToken() error = %v", err)
+	}
+	participants, _, err := uc.GetParticipants(room.ID)
+	if err != nil {
+		t.Fatalf("GetParticipants() error = %v", err)
+	}
+
+	// ban=true だけを指定した場合と同じく、セッションだけを参加禁止にする
+	if err := uc.KickParticipant(room.ID, participants[1].ClientID, ownerSessionID, &BanOptions{}); err != nil {
+		t.Fatalf("KickParticipant() error = %v", err)
+	}
+
+	tests := []*struct {
+		name    string
+		req     *model.JoinRequest
+		wantErr error
+	}{
+		{name: "rejoin with the kicked session cookie", req: &model.JoinRequest{ClientName: "someone", ClientIP: "192.0.2.2", SessionID: spammerSessionID}, wantErr: ErrBanned},
+		{name: "rejoin with the kicked bearer token", req: &model.JoinRequest{ClientName: "someone", ClientIP: "192.0.2.2", Token: spammerToken}, wantErr: ErrBanned},
+		{name: "invalid token is ignored", req: &model.JoinRequest{ClientName: "someone", ClientIP: "192.0.2.3", Token: "forged.token"}, wantErr: nil},
+		{name: "IP is not banned", req: &model.JoinRequest{ClientName: "other", ClientIP: "192.0.2.1"}, wantErr: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := uc.JoinRoom(context.Background(), room.ID, tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("JoinRoom() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
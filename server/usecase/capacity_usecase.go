@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// DefaultMaxParticipantsCeiling は1部屋あたりの参加者数のサーバー全体の上限の既定値
+const DefaultMaxParticipantsCeiling = 100
+
+var (
+	// ErrRoomFull は部屋の参加者数が上限に達している場合のエラー
+	ErrRoomFull = errors.New("room is full")
+	// ErrRoomLocked は部屋がロックされていて新規に参加できない場合のエラー
+	ErrRoomLocked = errors.New("room is locked")
+)
+
+// resolveMaxParticipants は部屋ごとの上限をサーバー全体の上限と照らし合わせて決める
+// 0の場合はサーバー全体の上限を使う
+func resolveMaxParticipants(requested, ceiling int) (int, error) {
+	if requested < 0 {
+		return 0, fmt.Errorf("%w: maxParticipants must not be negative", ErrInvalidSettings)
+	}
+	if requested == 0 {
+		return ceiling, nil
+	}
+	if requested > ceiling {
+		return 0, fmt.Errorf("%w: maxParticipants must be at most %d", ErrInvalidSettings, ceiling)
+	}
+	return requested, nil
+}
+
+// checkCanJoin は新しいクライアントを参加待ち、または認証済みに追加できるかを確認する
+// 参加待ちの列も参加者数の上限までに抑える
+// 呼び出し側で room.Mu をロックしておくこと
+func checkCanJoin(room *model.Room, authenticated bool) error {
+	if room.Locked {
+		return ErrRoomLocked
+	}
+	if authenticated {
+		return checkCapacity(room)
+	}
+	if len(room.UnauthenticatedClients) >= room.MaxParticipants {
+		return ErrRoomFull
+	}
+	return nil
+}
+
+// checkCapacity は認証済みのクライアントをもう1人増やせるかを確認する
+// 呼び出し側で room.Mu をロックしておくこと
+func checkCapacity(room *model.Room) error {
+	if len(room.AuthenticatedClients) >= room.MaxParticipants {
+		return ErrRoomFull
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+func TestJoinRoomCapacity(t *testing.T) {
+	uc := NewRoomUsecase()
+	if _, _, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour), MaxParticipants: uc.Limits().MaxParticipantsCeiling + 1}); err == nil {
+		t.Fatalf("CreateRoom() with maxParticipants over the ceiling should fail")
+	}
+
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour), MaxParticipants: 2})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+
+	tests := []*struct {
+		name    string
+		locked  bool
+		wantErr error
+	}{
+		{name: "second participant", wantErr: nil},
+		{name: "room is full", wantErr: ErrRoomFull},
+		{name: "room is locked", locked: true, wantErr: ErrRoomLocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := uc.UpdateRoomSettings(room.ID, &model.RoomSettings{Name: room.Name, Locked: &tt.locked}, ownerSessionID); err != nil {
+				t.Fatalf("UpdateRoomSettings() error = %v", err)
+			}
+			_, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "guest"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("JoinRoom() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
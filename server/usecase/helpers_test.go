@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// attachConn はセッションのクライアントにSSEと同じ送信キューの接続を追加する
+// 実際の接続と同じく addConnection を通すので、オンライン状態の通知も行われる
+func attachConn(t *testing.T, uc *RoomUsecase, roomID, sessionID string) *model.Connection {
+	t.Helper()
+	uc.RoomManager.Mu.Lock()
+	room := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	room.Mu.Lock()
+	client := findClientBySessionID(room, sessionID)
+	room.Mu.Unlock()
+	if client == nil {
+		t.Fatalf("no client for the session in room %s", roomID)
+	}
+
+	conn := &model.Connection{ID: generateConnectionID(), Send: make(chan *model.Event, 64)}
+	uc.addConnection(room, client, conn)
+	return conn
+}
+
+// drainMessages は接続の送信キューに溜まったフレームをすべて取り出す
+func drainMessages(t *testing.T, conn *model.Connection) []*model.Message {
+	t.Helper()
+	var messages []*model.Message
+	for {
+		select {
+		case event, ok := <-conn.Send:
+			if !ok {
+				return messages
+			}
+			var message model.Message
+			if err := json.Unmarshal(event.Data, &message); err != nil {
+				t.Fatalf("invalid frame %s: %v", event.Data, err)
+			}
+			messages = append(messages, &message)
+		default:
+			return messages
+		}
+	}
+}
+
+// messageTypes はフレームの Type を順に並べる
+func messageTypes(messages []*model.Message) []string {
+	types := make([]string, 0, len(messages))
+	for _, m := range messages {
+		types = append(types, m.Type)
+	}
+	return types
+}
+
+// detachConn は attachConn で追加した接続を閉じる
+// 実際の接続と同じく closeConnection を通すので、オフライン状態の通知や自動引き継ぎの予約も行われる
+func detachConn(t *testing.T, uc *RoomUsecase, roomID, sessionID string, conn *model.Connection) {
+	t.Helper()
+	uc.RoomManager.Mu.Lock()
+	room := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	room.Mu.Lock()
+	client := findClientBySessionID(room, sessionID)
+	room.Mu.Unlock()
+	if client == nil {
+		t.Fatalf("no client for the session in room %s", roomID)
+	}
+	uc.closeConnection(room, client, conn)
+}
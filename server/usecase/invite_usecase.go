@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	crand "crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// 招待の有効期限が指定されなかった場合の期間(部屋の有効期限の方が早ければそちらに合わせる)
+const defaultInviteTTL = 24 * time.Hour
+
+// CreateInvite は招待トークンを発行する(オーナー・モデレーター用)
+// maxUses が0なら使用回数は無制限、ttl が0なら既定の期間で期限切れになる
+func (uc *RoomUsecase) CreateInvite(roomID, sessionID string, maxUses int, ttl time.Duration, preApproved bool) (*model.Invite, error) {
+	if maxUses < 0 {
+		return nil, errors.New("maxUses must not be negative")
+	}
+	if ttl < 0 {
+		return nil, errors.New("expiresIn must not be negative")
+	}
+	if ttl == 0 {
+		ttl = defaultInviteTTL
+	}
+
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return nil, errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	actor, err := authorize(room, sessionID, PermManageInvites)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := generateInviteToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	if !room.Expires.IsZero() && room.Expires.Before(expiresAt) {
+		expiresAt = room.Expires
+	}
+
+	invite := &model.Invite{
+		Token:       token,
+		MaxUses:     maxUses,
+		ExpiresAt:   expiresAt,
+		PreApproved: preApproved,
+		CreatedBy:   actor.ClientID,
+		CreatedAt:   now,
+	}
+	if room.Invites == nil {
+		room.Invites = map[string]*model.Invite{}
+	}
+	room.Invites[token] = invite
+
+	copied := *invite
+	return &copied, nil
+}
+
+// ListInvites は部屋の招待を作成日時順に返す(オーナー・モデレーター用)
+func (uc *RoomUsecase) ListInvites(roomID, sessionID string) ([]model.Invite, error) {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return nil, errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if _, err := authorize(room, sessionID, PermManageInvites); err != nil {
+		return nil, err
+	}
+
+	invites := make([]model.Invite, 0, len(room.Invites))
+	for _, invite := range room.Invites {
+		invites = append(invites, *invite)
+	}
+	sort.Slice(invites, func(i, j int) bool {
+		return invites[i].CreatedAt.Before(invites[j].CreatedAt)
+	})
+	return invites, nil
+}
+
+// RevokeInvite は招待を取り消す(オーナー・モデレーター用)
+func (uc *RoomUsecase) RevokeInvite(roomID, sessionID, token string) error {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if _, err := authorize(room, sessionID, PermManageInvites); err != nil {
+		return err
+	}
+
+	if _, exists := room.Invites[token]; !exists {
+		return errors.New("invite not found")
+	}
+	delete(room.Invites, token)
+	return nil
+}
+
+// checkInvite は招待トークンがまだ使えるかを確認する
+// 呼び出し側で room.Mu をロックしておくこと
+func checkInvite(room *model.Room, token string, now time.Time) (*model.Invite, error) {
+	invite, exists := room.Invites[token]
+	if !exists {
+		return nil, errors.New("invite not found")
+	}
+	if !now.Before(invite.ExpiresAt) {
+		return nil, errors.New("invite has expired")
+	}
+	if invite.MaxUses > 0 && invite.Uses >= invite.MaxUses {
+		return nil, errors.New("invite has been used up")
+	}
+	return invite, nil
+}
+
+func generateInviteToken() (string, error) {
+	b := make([]byte, 18)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
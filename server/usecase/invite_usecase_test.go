@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+func TestJoinRoomWithInvite(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", RequiresAuth: true, Password: "secret", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	invite, err := uc.CreateInvite(room.ID, ownerSessionID, 1, time.Minute, true)
+	if err != nil {
+		t.Fatalf("CreateInvite() error = %v", err)
+	}
+
+	// 招待があればパスワードなしで、承認待ちも経ずに参加できる
+	sessionID, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "guest", InviteToken: invite.Token})
+	if err != nil {
+		t.Fatalf("JoinRoom() with invite error = %v", err)
+	}
+	isAuth, err := uc.IsAuth(room.ID, sessionID)
+	if err != nil || !isAuth {
+		t.Errorf("IsAuth() = %v, %v, want true", isAuth, err)
+	}
+
+	// 使用回数の上限に達した招待は使えない
+	if _, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "guest2", InviteToken: invite.Token}); err == nil {
+		t.Errorf("JoinRoom() with a used up invite should fail")
+	}
+
+	// 取り消した招待は使えない
+	invite, err = uc.CreateInvite(room.ID, ownerSessionID, 0, time.Minute, false)
+	if err != nil {
+		t.Fatalf("CreateInvite() error = %v", err)
+	}
+	if err := uc.RevokeInvite(room.ID, ownerSessionID, invite.Token); err != nil {
+		t.Fatalf("RevokeInvite() error = %v", err)
+	}
+	if _, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "guest3", InviteToken: invite.Token}); err == nil {
+		t.Errorf("JoinRoom() with a revoked invite should fail")
+	}
+}
@@ -0,0 +1,380 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// ErrJoinDenied は参加ポリシーによって参加が拒否された場合のエラー
+var ErrJoinDenied = errors.New("join request denied")
+
+const (
+	// 部屋ごとに保持するポリシーの判定の記録数
+	maxJoinDecisions = 256
+	// 部屋ごとに設定できるポリシーの数
+	maxJoinPolicies = 10
+
+	// http_callback の問い合わせのタイムアウト
+	defaultJoinCallbackTimeout = 5 * time.Second
+	maxJoinCallbackTimeout     = 10 * time.Second
+	// http_callback の応答の最大サイズ
+	maxJoinCallbackResponse = 4 * 1024
+
+	// ポリシーが判定しなかった場合に記録するポリシー名
+	defaultJoinPolicyName = "default"
+)
+
+// namePatternPolicy は表示名が正規表現に一致すれば承認する
+type namePatternPolicy struct {
+	spec model.JoinPolicySpec
+	re   *regexp.Regexp
+}
+
+func (p *namePatternPolicy) Name() string               { return p.spec.Type }
+func (p *namePatternPolicy) Spec() model.JoinPolicySpec { return p.spec }
+
+func (p *namePatternPolicy) Evaluate(_ context.Context, candidate *model.JoinCandidate) (model.JoinVerdict, error) {
+	if p.re.MatchString(candidate.Name) {
+		return model.JoinVerdict{Outcome: model.JoinApprove, Reason: "name matches the allowlist"}, nil
+	}
+	return model.JoinVerdict{Outcome: model.JoinPending}, nil
+}
+
+// inviteRequiredPolicy は招待トークンを持たない参加を拒否する
+type inviteRequiredPolicy struct {
+	spec model.JoinPolicySpec
+}
+
+func (p *inviteRequiredPolicy) Name() string               { return p.spec.Type }
+func (p *inviteRequiredPolicy) Spec() model.JoinPolicySpec { return p.spec }
+
+func (p *inviteRequiredPolicy) Evaluate(_ context.Context, candidate *model.JoinCandidate) (model.JoinVerdict, error) {
+	if !candidate.HasInvite {
+		return model.JoinVerdict{Outcome: model.JoinDeny, Reason: "an invite is required to join this room"}, nil
+	}
+	return model.JoinVerdict{Outcome: model.JoinPending}, nil
+}
+
+// admissionPolicy は承認した参加が実際に成立したかどうかで状態を変えるポリシー
+// 承認の判定の後でも満員・表示名の重複・ロックなどで参加に失敗することがあるので、
+// JoinRoom は参加が確定したか失敗した時点で settle を呼ぶ
+type admissionPolicy interface {
+	settle(clientID string, admitted bool)
+}
+
+// firstNPolicy はポリシーを設定してから最初のN人を承認する
+// 承認した時点では枠を予約するだけで、実際に参加できた場合にだけ人数を数える
+type firstNPolicy struct {
+	spec     model.JoinPolicySpec
+	approved int
+	reserved map[string]bool // 承認したが参加がまだ確定していないクライアントID
+	mu       sync.Mutex
+}
+
+func (p *firstNPolicy) Name() string               { return p.spec.Type }
+func (p *firstNPolicy) Spec() model.JoinPolicySpec { return p.spec }
+
+func (p *firstNPolicy) Evaluate(_ context.Context, candidate *model.JoinCandidate) (model.JoinVerdict, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.approved+len(p.reserved) >= p.spec.Count {
+		return model.JoinVerdict{Outcome: model.JoinPending}, nil
+	}
+	p.reserved[candidate.ClientID] = true
+	return model.JoinVerdict{Outcome: model.JoinApprove, Reason: fmt.Sprintf("within the first %d participants", p.spec.Count)}, nil
+}
+
+func (p *firstNPolicy) settle(clientID string, admitted bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.reserved[clientID] {
+		return
+	}
+	delete(p.reserved, clientID)
+	if admitted {
+		p.approved++
+	}
+}
+
+// autoDenyTimeoutPolicy は承認待ちのまま一定時間が過ぎた参加リクエストを拒否する
+type autoDenyTimeoutPolicy struct {
+	spec model.JoinPolicySpec
+}
+
+func (p *autoDenyTimeoutPolicy) Name() string               { return p.spec.Type }
+func (p *autoDenyTimeoutPolicy) Spec() model.JoinPolicySpec { return p.spec }
+
+func (p *autoDenyTimeoutPolicy) Evaluate(_ context.Context, _ *model.JoinCandidate) (model.JoinVerdict, error) {
+	return model.JoinVerdict{
+		Outcome:   model.JoinPending,
+		DenyAfter: time.Duration(p.spec.TimeoutSeconds) * time.Second,
+	}, nil
+}
+
+// httpCallbackPolicy は外部の承認サーバーに問い合わせる
+// 参加者の情報を JSON で POST し、{"outcome": "approve" | "deny" | "pending", "reason": "..."} を受け取る
+type httpCallbackPolicy struct {
+	spec   model.JoinPolicySpec
+	client *http.Client
+}
+
+func (p *httpCallbackPolicy) Name() string               { return p.spec.Type }
+func (p *httpCallbackPolicy) Spec() model.JoinPolicySpec { return p.spec }
+
+func (p *httpCallbackPolicy) Evaluate(ctx context.Context, candidate *model.JoinCandidate) (model.JoinVerdict, error) {
+	body, err := json.Marshal(candidate)
+	if err != nil {
+		return model.JoinVerdict{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.spec.URL, bytes.NewReader(body))
+	if err != nil {
+		return model.JoinVerdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return model.JoinVerdict{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return model.JoinVerdict{}, fmt.Errorf("approver responded with status %d", res.StatusCode)
+	}
+
+	var reply struct {
+		Outcome model.JoinOutcome `json:"outcome"`
+		Reason  string            `json:"reason"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxJoinCallbackResponse)).Decode(&reply); err != nil {
+		return model.JoinVerdict{}, fmt.Errorf("invalid approver response: %w", err)
+	}
+	switch reply.Outcome {
+	case model.JoinApprove, model.JoinDeny, model.JoinPending:
+	default:
+		return model.JoinVerdict{}, fmt.Errorf("invalid approver outcome %q", reply.Outcome)
+	}
+	return model.JoinVerdict{Outcome: reply.Outcome, Reason: reply.Reason}, nil
+}
+
+// buildJoinPolicy は設定から組み込みのポリシーを作る
+func (uc *RoomUsecase) buildJoinPolicy(spec model.JoinPolicySpec) (model.JoinPolicy, error) {
+	switch spec.Type {
+	case "name_regex":
+		re, err := regexp.Compile(spec.Pattern)
+		if err != nil || spec.Pattern == "" {
+			return nil, fmt.Errorf("name_regex: invalid pattern %q", spec.Pattern)
+		}
+		return &namePatternPolicy{spec: spec, re: re}, nil
+	case "invite_required":
+		return &inviteRequiredPolicy{spec: spec}, nil
+	case "first_n":
+		if spec.Count <= 0 {
+			return nil, errors.New("first_n: count must be positive")
+		}
+		return &firstNPolicy{spec: spec, reserved: map[string]bool{}}, nil
+	case "auto_deny_timeout":
+		if spec.TimeoutSeconds <= 0 {
+			return nil, errors.New("auto_deny_timeout: timeoutSeconds must be positive")
+		}
+		return &autoDenyTimeoutPolicy{spec: spec}, nil
+	case "http_callback":
+		// 部屋のオーナーが任意のURLにサーバーからリクエストを送らせられないよう、許可されたURLに限る
+		if !uc.joinCallbackAllowed(spec.URL) {
+			return nil, fmt.Errorf("http_callback: url %q is not allowed on this server", spec.URL)
+		}
+		timeout := time.Duration(spec.TimeoutSeconds) * time.Second
+		if timeout <= 0 {
+			timeout = defaultJoinCallbackTimeout
+		}
+		if timeout > maxJoinCallbackTimeout {
+			return nil, fmt.Errorf("http_callback: timeoutSeconds must be at most %d", int(maxJoinCallbackTimeout/time.Second))
+		}
+		client := &http.Client{
+			Timeout: timeout,
+			// リダイレクトで許可されていないURLに向かわないようにする
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+		return &httpCallbackPolicy{spec: spec, client: client}, nil
+	}
+	return nil, fmt.Errorf("unknown join policy type %q", spec.Type)
+}
+
+// joinCallbackAllowed はURLがサーバーで許可された問い合わせ先か、その配下のパスかを返す
+// "https://approver.example" が "https://approver.example.evil" に一致しないよう、パスの区切りで比べる
+func (uc *RoomUsecase) joinCallbackAllowed(url string) bool {
+	for _, allowed := range uc.Limits().JoinCallbackAllowlist {
+		if allowed == "" {
+			continue
+		}
+		if url == allowed || strings.HasPrefix(url, strings.TrimSuffix(allowed, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateJoinPolicies はポリシーを順に評価し、最初に承認か拒否を返したポリシーの判定を採用する
+// どのポリシーも判定しなければ承認待ちのままにする
+// 自動拒否までの時間は評価したポリシーのうち最も短いものを使う
+// エラーになったポリシーは判定しなかったものとして扱う
+func evaluateJoinPolicies(ctx context.Context, policies []model.JoinPolicy, candidate *model.JoinCandidate) (model.JoinVerdict, string) {
+	var denyAfter time.Duration
+	for _, policy := range policies {
+		verdict, err := policy.Evaluate(ctx, candidate)
+		if err != nil {
+			slog.Warn("join policy failed", "policy", policy.Name(), "error", err)
+			continue
+		}
+		if verdict.DenyAfter > 0 && (denyAfter == 0 || verdict.DenyAfter < denyAfter) {
+			denyAfter = verdict.DenyAfter
+		}
+		if verdict.Outcome == model.JoinApprove || verdict.Outcome == model.JoinDeny {
+			return verdict, policy.Name()
+		}
+	}
+	return model.JoinVerdict{Outcome: model.JoinPending, DenyAfter: denyAfter}, defaultJoinPolicyName
+}
+
+// settleJoinPolicies は参加が確定したか失敗したかを、予約を持つポリシーに知らせる
+func settleJoinPolicies(policies []model.JoinPolicy, clientID string, admitted bool) {
+	for _, policy := range policies {
+		if p, ok := policy.(admissionPolicy); ok {
+			p.settle(clientID, admitted)
+		}
+	}
+}
+
+// recordJoinDecision はポリシーによる判定を記録する
+// 呼び出し側で room.Mu をロックしておくこと
+func recordJoinDecision(room *model.Room, policy string, client *model.Client, outcome model.JoinOutcome, reason string) {
+	room.JoinDecisions = append(room.JoinDecisions, &model.JoinDecision{
+		Policy:    policy,
+		ClientID:  client.ClientID,
+		Name:      client.Name,
+		Outcome:   outcome,
+		Reason:    reason,
+		DecidedAt: time.Now(),
+	})
+	if len(room.JoinDecisions) > maxJoinDecisions {
+		room.JoinDecisions = room.JoinDecisions[len(room.JoinDecisions)-maxJoinDecisions:]
+	}
+}
+
+// scheduleJoinTimeout は承認待ちのクライアントが一定時間後も承認待ちのままなら拒否する
+// 呼び出し側で room.Mu をロックしておくこと
+func (uc *RoomUsecase) scheduleJoinTimeout(room *model.Room, client *model.Client, after time.Duration) {
+	time.AfterFunc(after, func() {
+		uc.RoomManager.Mu.Lock()
+		current := uc.RoomManager.Rooms[room.ID]
+		uc.RoomManager.Mu.Unlock()
+		if current != room {
+			// 部屋が削除・期限切れになっている
+			return
+		}
+
+		room.Mu.Lock()
+		defer room.Mu.Unlock()
+		reason := "join request timed out"
+		if err := uc.denyPending(room, client.ClientID, reason); err != nil {
+			// 既に承認・拒否・取り消しされている
+			return
+		}
+		recordJoinDecision(room, "auto_deny_timeout", client, model.JoinDeny, reason)
+		notifyParticipantsChanged(room)
+	})
+}
+
+// SetJoinPolicies は部屋の参加ポリシーを置き換える(オーナー専用)
+// ポリシーは指定した順に評価される。空の一覧を渡すとポリシーを解除する
+func (uc *RoomUsecase) SetJoinPolicies(roomID, sessionID string, specs []model.JoinPolicySpec) ([]model.JoinPolicySpec, error) {
+	if len(specs) > maxJoinPolicies {
+		return nil, fmt.Errorf("at most %d join policies can be set", maxJoinPolicies)
+	}
+	policies := make([]model.JoinPolicy, 0, len(specs))
+	for _, spec := range specs {
+		policy, err := uc.buildJoinPolicy(spec)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return nil, errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if _, err := authorize(room, sessionID, PermUpdateSettings); err != nil {
+		return nil, err
+	}
+	room.JoinPolicies = policies
+	return joinPolicySpecs(policies), nil
+}
+
+// GetJoinPolicies は部屋の参加ポリシーの設定を返す(オーナー・モデレーター用)
+func (uc *RoomUsecase) GetJoinPolicies(roomID, sessionID string) ([]model.JoinPolicySpec, error) {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return nil, errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if _, err := authorize(room, sessionID, PermApprove); err != nil {
+		return nil, err
+	}
+	return joinPolicySpecs(room.JoinPolicies), nil
+}
+
+// ListJoinDecisions はポリシーによる判定の記録を古い順に返す(オーナー・モデレーター用)
+func (uc *RoomUsecase) ListJoinDecisions(roomID, sessionID string) ([]model.JoinDecision, error) {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return nil, errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if _, err := authorize(room, sessionID, PermApprove); err != nil {
+		return nil, err
+	}
+	decisions := make([]model.JoinDecision, 0, len(room.JoinDecisions))
+	for _, decision := range room.JoinDecisions {
+		decisions = append(decisions, *decision)
+	}
+	return decisions, nil
+}
+
+func joinPolicySpecs(policies []model.JoinPolicy) []model.JoinPolicySpec {
+	specs := make([]model.JoinPolicySpec, 0, len(policies))
+	for _, policy := range policies {
+		specs = append(specs, policy.Spec())
+	}
+	return specs
+}
@@ -0,0 +1,256 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+func TestJoinPolicies(t *testing.T) {
+	approver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var candidate model.JoinCandidate
+		if err := json.NewDecoder(r.Body).Decode(&candidate); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		outcome := model.JoinPending
+		switch candidate.Name {
+		case "vip":
+			outcome = model.JoinApprove
+		case "troll":
+			outcome = model.JoinDeny
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"outcome": string(outcome), "reason": "external approver"})
+	}))
+	defer approver.Close()
+
+	tests := []*struct {
+		name        string
+		specs       []model.JoinPolicySpec
+		clientName  string
+		wantErr     error
+		wantPending bool
+		wantPolicy  string
+	}{
+		{
+			name:       "name regex approves matching names",
+			specs:      []model.JoinPolicySpec{{Type: "name_regex", Pattern: "^staff-"}},
+			clientName: "staff-alice",
+			wantPolicy: "name_regex",
+		},
+		{
+			name:        "name regex leaves other names pending",
+			specs:       []model.JoinPolicySpec{{Type: "name_regex", Pattern: "^staff-"}},
+			clientName:  "bob",
+			wantPending: true,
+			wantPolicy:  defaultJoinPolicyName,
+		},
+		{
+			name:       "invite required denies joins without an invite",
+			specs:      []model.JoinPolicySpec{{Type: "invite_required"}, {Type: "first_n", Count: 10}},
+			clientName: "bob",
+			wantErr:    ErrJoinDenied,
+			wantPolicy: "invite_required",
+		},
+		{
+			name:       "first n approves",
+			specs:      []model.JoinPolicySpec{{Type: "first_n", Count: 1}},
+			clientName: "bob",
+			wantPolicy: "first_n",
+		},
+		{
+			name:       "http callback approves",
+			specs:      []model.JoinPolicySpec{{Type: "http_callback", URL: approver.URL + "/approve"}},
+			clientName: "vip",
+			wantPolicy: "http_callback",
+		},
+		{
+			name:       "http callback denies",
+			specs:      []model.JoinPolicySpec{{Type: "http_callback", URL: approver.URL + "/approve"}},
+			clientName: "troll",
+			wantErr:    ErrJoinDenied,
+			wantPolicy: "http_callback",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := NewRoomUsecase()
+			limits := DefaultLimits()
+			limits.JoinCallbackAllowlist = []string{approver.URL}
+			uc.SetLimits(limits)
+			room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour), RequiresAuth: true})
+			if err != nil {
+				t.Fatalf("CreateRoom() error = %v", err)
+			}
+			if _, err := uc.SetJoinPolicies(room.ID, ownerSessionID, tt.specs); err != nil {
+				t.Fatalf("SetJoinPolicies() error = %v", err)
+			}
+
+			_, err = uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: tt.clientName})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("JoinRoom() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				_, pending, err := uc.GetParticipants(room.ID)
+				if err != nil {
+					t.Fatalf("GetParticipants() error = %v", err)
+				}
+				if got := len(pending) == 1; got != tt.wantPending {
+					t.Errorf("pending = %v, want %v", got, tt.wantPending)
+				}
+			}
+
+			decisions, err := uc.ListJoinDecisions(room.ID, ownerSessionID)
+			if err != nil {
+				t.Fatalf("ListJoinDecisions() error = %v", err)
+			}
+			if len(decisions) != 1 || decisions[0].Policy != tt.wantPolicy {
+				t.Errorf("decisions = %+v, want one decision by %q", decisions, tt.wantPolicy)
+			}
+		})
+	}
+}
+
+func TestJoinPolicyCallbackNotAllowed(t *testing.T) {
+	uc := NewRoomUsecase()
+	limits := DefaultLimits()
+	limits.JoinCallbackAllowlist = []string{"https://approver.example"}
+	uc.SetLimits(limits)
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	for _, url := range []string{"http://169.254.169.254/", "https://approver.example.evil/"} {
+		if _, err := uc.SetJoinPolicies(room.ID, ownerSessionID, []model.JoinPolicySpec{{Type: "http_callback", URL: url}}); err == nil {
+			t.Errorf("SetJoinPolicies() with url %q should fail", url)
+		}
+	}
+}
+
+func TestJoinTimeoutDeniesPendingClient(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour), RequiresAuth: true})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	if _, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "guest"}); err != nil {
+		t.Fatalf("JoinRoom() error = %v", err)
+	}
+
+	r := uc.RoomManager.Rooms[room.ID]
+	r.Mu.Lock()
+	uc.scheduleJoinTimeout(r, r.UnauthenticatedClients[0], 10*time.Millisecond)
+	r.Mu.Unlock()
+	time.Sleep(100 * time.Millisecond)
+
+	_, pending, err := uc.GetParticipants(room.ID)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("pending = %v, err = %v, want no pending clients", pending, err)
+	}
+	decisions, err := uc.ListJoinDecisions(room.ID, ownerSessionID)
+	if err != nil || len(decisions) != 1 || decisions[0].Policy != "auto_deny_timeout" {
+		t.Errorf("decisions = %+v, err = %v, want one auto_deny_timeout decision", decisions, err)
+	}
+}
+
+func TestFirstNCountsOnlyAdmittedClients(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour), RequiresAuth: true, MaxParticipants: 2})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	// オーナーと承認済みの参加者で満員にしておく
+	if _, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "member"}); err != nil {
+		t.Fatalf("JoinRoom() error = %v", err)
+	}
+	_, pending, err := uc.GetParticipants(room.ID)
+	if err != nil {
+		t.Fatalf("GetParticipants() error = %v", err)
+	}
+	if err := uc.Authenticate(room.ID, pending[0].ClientID, ownerSessionID); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if _, err := uc.SetJoinPolicies(room.ID, ownerSessionID, []model.JoinPolicySpec{{Type: "first_n", Count: 1}}); err != nil {
+		t.Fatalf("SetJoinPolicies() error = %v", err)
+	}
+
+	tests := []*struct {
+		name            string
+		maxParticipants int
+		wantErr         error
+		wantPending     bool
+	}{
+		{name: "approved but the room is full", maxParticipants: 2, wantErr: ErrRoomFull},
+		{name: "failed join did not use the slot", maxParticipants: 3, wantPending: false},
+		{name: "slot is used", maxParticipants: 4, wantPending: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := uc.RoomManager.Rooms[room.ID]
+			r.Mu.Lock()
+			r.MaxParticipants = tt.maxParticipants
+			r.Mu.Unlock()
+
+			_, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "guest"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("JoinRoom() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			_, pending, err := uc.GetParticipants(room.ID)
+			if err != nil {
+				t.Fatalf("GetParticipants() error = %v", err)
+			}
+			if got := len(pending) == 1; got != tt.wantPending {
+				t.Errorf("pending = %v, want %v", got, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestJoinRoomCancelledDuringCallback(t *testing.T) {
+	release := make(chan struct{})
+	approver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// テストが終わるまで応答しない
+		<-release
+	}))
+	defer approver.Close()
+	defer close(release)
+
+	uc := NewRoomUsecase()
+	limits := DefaultLimits()
+	limits.JoinCallbackAllowlist = []string{approver.URL}
+	uc.SetLimits(limits)
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour), RequiresAuth: true})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	if _, err := uc.SetJoinPolicies(room.ID, ownerSessionID, []model.JoinPolicySpec{{Type: "http_callback", URL: approver.URL}}); err != nil {
+		t.Fatalf("SetJoinPolicies() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = uc.JoinRoom(ctx, room.ID, &model.JoinRequest{ClientName: "guest"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("JoinRoom() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed >= defaultJoinCallbackTimeout {
+		t.Errorf("JoinRoom() took %v, want it to stop when the request is cancelled", elapsed)
+	}
+	_, pending, err := uc.GetParticipants(room.ID)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("pending = %v, err = %v, want no pending clients", pending, err)
+	}
+	if got := uc.AdminStats().Sessions; got != 1 {
+		t.Errorf("sessions = %d, want only the owner's", got)
+	}
+}
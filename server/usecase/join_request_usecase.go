@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"errors"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// BulkResult は一括承認・一括拒否の結果を表す構造体
+type BulkResult struct {
+	Succeeded []string          `json:"succeeded"` // 処理できたクライアントID
+	Failed    map[string]string `json:"failed"`    // 処理できなかったクライアントIDと理由
+}
+
+// DenyClient は参加待ちのクライアントを拒否する(オーナー・モデレーター用)
+// 拒否されたクライアントには join_denied フレームを送ってから接続を閉じる
+func (uc *RoomUsecase) DenyClient(roomID, sessionID, clientID, reason string) error {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if _, err := authorize(room, sessionID, PermApprove); err != nil {
+		return err
+	}
+	if err := uc.denyPending(room, clientID, reason); err != nil {
+		return err
+	}
+	notifyParticipantsChanged(room)
+	return nil
+}
+
+// ApproveClients は参加待ちのクライアントをまとめて承認する(オーナー・モデレーター用)
+func (uc *RoomUsecase) ApproveClients(roomID, sessionID string, clientIDs []string) (*BulkResult, error) {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return nil, errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if _, err := authorize(room, sessionID, PermApprove); err != nil {
+		return nil, err
+	}
+
+	result := &BulkResult{Succeeded: []string{}, Failed: map[string]string{}}
+	for _, clientID := range clientIDs {
+		if err := approvePending(room, clientID); err != nil {
+			result.Failed[clientID] = err.Error()
+			continue
+		}
+		result.Succeeded = append(result.Succeeded, clientID)
+	}
+	if len(result.Succeeded) > 0 {
+		notifyParticipantsChanged(room)
+	}
+	return result, nil
+}
+
+// DenyClients は参加待ちのクライアントをまとめて拒否する(オーナー・モデレーター用)
+func (uc *RoomUsecase) DenyClients(roomID, sessionID string, clientIDs []string, reason string) (*BulkResult, error) {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return nil, errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if _, err := authorize(room, sessionID, PermApprove); err != nil {
+		return nil, err
+	}
+
+	result := &BulkResult{Succeeded: []string{}, Failed: map[string]string{}}
+	for _, clientID := range clientIDs {
+		if err := uc.denyPending(room, clientID, reason); err != nil {
+			result.Failed[clientID] = err.Error()
+			continue
+		}
+		result.Succeeded = append(result.Succeeded, clientID)
+	}
+	if len(result.Succeeded) > 0 {
+		notifyParticipantsChanged(room)
+	}
+	return result, nil
+}
+
+// CancelJoinRequest は参加待ちのクライアントが自分の参加リクエストを取り下げる
+func (uc *RoomUsecase) CancelJoinRequest(roomID, sessionID string) error {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	for i, client := range room.UnauthenticatedClients {
+		if client.SessionID == sessionID {
+			room.UnauthenticatedClients = append(room.UnauthenticatedClients[:i], room.UnauthenticatedClients[i+1:]...)
+			revokeSession(uc.RoomManager.Sessions, client.SessionID)
+			disconnectClient(client)
+			notifyParticipantsChanged(room)
+			return nil
+		}
+	}
+	return errors.New("no pending join request found")
+}
+
+// approvePending は参加待ちのクライアントを認証済みに移す
+// 呼び出し側で room.Mu をロックしておくこと
+func approvePending(room *model.Room, clientID string) error {
+	for i, client := range room.UnauthenticatedClients {
+		if client.ClientID == clientID {
+			if err := checkCapacity(room); err != nil {
+				return err
+			}
+			client.JoinedAt = time.Now()
+			room.AuthenticatedClients = append(room.AuthenticatedClients, client)
+			room.UnauthenticatedClients = append(room.UnauthenticatedClients[:i], room.UnauthenticatedClients[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("client not found in the room")
+}
+
+// denyPending は参加待ちのクライアントを取り除き、join_denied を送って接続を閉じる
+// 呼び出し側で room.Mu をロックしておくこと
+func (uc *RoomUsecase) denyPending(room *model.Room, clientID, reason string) error {
+	for i, client := range room.UnauthenticatedClients {
+		if client.ClientID != clientID {
+			continue
+		}
+		room.UnauthenticatedClients = append(room.UnauthenticatedClients[:i], room.UnauthenticatedClients[i+1:]...)
+
+		if event := directEvent(room, &model.Message{
+			RoomID:    room.ID,
+			Sentence:  reason,
+			Timestamp: time.Now().Unix(),
+			Type:      "join_denied",
+		}); event != nil {
+			sendToClient(client, event)
+		}
+		revokeSession(uc.RoomManager.Sessions, client.SessionID)
+		disconnectClient(client)
+		return nil
+	}
+	return errors.New("client not found in the room")
+}
+
+// notifyParticipantsChanged は参加者一覧が変わったことを部屋全体に通知する
+// 呼び出し側で room.Mu をロックしておくこと
+func notifyParticipantsChanged(room *model.Room) {
+	event := recordEvent(room, &model.Message{
+		RoomID:    room.ID,
+		Timestamp: time.Now().Unix(),
+		Type:      "participants_update",
+	}, false)
+	if event != nil {
+		sendToAll(room, event)
+	}
+}
@@ -0,0 +1,215 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// newGatedRoom は承認制の部屋を作り、guests の名前で参加待ちのクライアントを追加する
+// 部屋ID・オーナーのセッションID・参加待ちのセッションIDとクライアントIDを返す
+func newGatedRoom(t *testing.T, uc *RoomUsecase, guests ...string) (roomID, ownerSessionID string, sessionIDs, clientIDs []string) {
+	t.Helper()
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "gated", Owner: "owner", Expires: time.Now().Add(time.Hour), RequiresAuth: true})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	for _, name := range guests {
+		sessionID, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: name})
+		if err != nil {
+			t.Fatalf("JoinRoom() error = %v", err)
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	_, pending, err := uc.GetParticipants(room.ID)
+	if err != nil {
+		t.Fatalf("GetParticipants() error = %v", err)
+	}
+	for _, p := range pending {
+		clientIDs = append(clientIDs, p.ClientID)
+	}
+	return room.ID, ownerSessionID, sessionIDs, clientIDs
+}
+
+// assertDenied は拒否されたクライアントのセッションが失効し、join_denied を受け取ってから切断されたことを確認する
+func assertDenied(t *testing.T, uc *RoomUsecase, roomID, sessionID string, conn *model.Connection, reason string) {
+	t.Helper()
+	if _, err := uc.ValidateSession(roomID, sessionID); err == nil {
+		t.Errorf("ValidateSession() of the denied client should fail")
+	}
+	// 切断で送信キューが閉じられているので、drainMessages はキューの最後まで読んで返る
+	messages := drainMessages(t, conn)
+	if !conn.Closed {
+		t.Fatalf("connection of the denied client should be closed")
+	}
+	if len(messages) == 0 || messages[len(messages)-1].Type != "join_denied" {
+		t.Fatalf("frames = %v, want join_denied as the last frame", messageTypes(messages))
+	}
+	if got := messages[len(messages)-1].Sentence; got != reason {
+		t.Errorf("join_denied reason = %q, want %q", got, reason)
+	}
+}
+
+// hasType はフレームに typ が含まれるかを返す
+func hasType(messages []*model.Message, typ string) bool {
+	for _, m := range messages {
+		if m.Type == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAuthenticate(t *testing.T) {
+	uc := NewRoomUsecase()
+	roomID, ownerSessionID, sessionIDs, clientIDs := newGatedRoom(t, uc, "guest")
+	ownerConn := attachConn(t, uc, roomID, ownerSessionID)
+	drainMessages(t, ownerConn)
+
+	if err := uc.Authenticate(roomID, clientIDs[0], sessionIDs[0]); err == nil {
+		t.Errorf("Authenticate() by a pending client should fail")
+	}
+	if err := uc.Authenticate(roomID, clientIDs[0], ownerSessionID); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if messages := drainMessages(t, ownerConn); !hasType(messages, "participants_update") {
+		t.Errorf("frames = %v, want participants_update", messageTypes(messages))
+	}
+	participants, pending, err := uc.GetParticipants(roomID)
+	if err != nil {
+		t.Fatalf("GetParticipants() error = %v", err)
+	}
+	if len(participants) != 2 || len(pending) != 0 {
+		t.Errorf("GetParticipants() = %d participants, %d pending, want 2 and 0", len(participants), len(pending))
+	}
+}
+
+func TestDenyClient(t *testing.T) {
+	uc := NewRoomUsecase()
+	roomID, ownerSessionID, sessionIDs, clientIDs := newGatedRoom(t, uc, "guest", "other")
+	ownerConn := attachConn(t, uc, roomID, ownerSessionID)
+	guestConn := attachConn(t, uc, roomID, sessionIDs[0])
+	drainMessages(t, ownerConn)
+
+	if err := uc.DenyClient(roomID, sessionIDs[1], clientIDs[0], "no"); err == nil {
+		t.Errorf("DenyClient() by a pending client should fail")
+	}
+	if err := uc.DenyClient(roomID, ownerSessionID, clientIDs[0], "not invited"); err != nil {
+		t.Fatalf("DenyClient() error = %v", err)
+	}
+	assertDenied(t, uc, roomID, sessionIDs[0], guestConn, "not invited")
+	if messages := drainMessages(t, ownerConn); !hasType(messages, "participants_update") {
+		t.Errorf("owner frames = %v, want participants_update", messageTypes(messages))
+	}
+	if err := uc.DenyClient(roomID, ownerSessionID, clientIDs[0], "again"); err == nil {
+		t.Errorf("DenyClient() of an already denied client should fail")
+	}
+	if _, err := uc.ValidateSession(roomID, sessionIDs[1]); err != nil {
+		t.Errorf("ValidateSession() of the other pending client error = %v", err)
+	}
+}
+
+func TestBulkApproveDeny(t *testing.T) {
+	uc := NewRoomUsecase()
+	roomID, ownerSessionID, sessionIDs, clientIDs := newGatedRoom(t, uc, "a", "b", "c")
+	conns := make([]*model.Connection, len(sessionIDs))
+	for i, sessionID := range sessionIDs {
+		conns[i] = attachConn(t, uc, roomID, sessionID)
+	}
+
+	tests := []*struct {
+		name          string
+		run           func() (*BulkResult, error)
+		wantSucceeded []string
+		wantFailed    []string
+	}{
+		{
+			name: "approve one and a missing client",
+			run: func() (*BulkResult, error) {
+				return uc.ApproveClients(roomID, ownerSessionID, []string{clientIDs[0], "missing"})
+			},
+			wantSucceeded: []string{clientIDs[0]},
+			wantFailed:    []string{"missing"},
+		},
+		{
+			name:          "deny the rest and an already approved client",
+			run:           func() (*BulkResult, error) { return uc.DenyClients(roomID, ownerSessionID, clientIDs, "closed") },
+			wantSucceeded: []string{clientIDs[1], clientIDs[2]},
+			wantFailed:    []string{clientIDs[0]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.run()
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if len(result.Succeeded) != len(tt.wantSucceeded) {
+				t.Fatalf("Succeeded = %v, want %v", result.Succeeded, tt.wantSucceeded)
+			}
+			for i, id := range tt.wantSucceeded {
+				if result.Succeeded[i] != id {
+					t.Errorf("Succeeded = %v, want %v", result.Succeeded, tt.wantSucceeded)
+				}
+			}
+			if len(result.Failed) != len(tt.wantFailed) {
+				t.Fatalf("Failed = %v, want %v", result.Failed, tt.wantFailed)
+			}
+			for _, id := range tt.wantFailed {
+				if _, ok := result.Failed[id]; !ok {
+					t.Errorf("Failed = %v, want %v", result.Failed, tt.wantFailed)
+				}
+			}
+		})
+	}
+
+	if _, err := uc.ApproveClients(roomID, sessionIDs[0], []string{clientIDs[1]}); err == nil {
+		t.Errorf("ApproveClients() by a member should fail")
+	}
+	if _, err := uc.ValidateSession(roomID, sessionIDs[0]); err != nil {
+		t.Errorf("ValidateSession() of the approved client error = %v", err)
+	}
+	if messages := drainMessages(t, conns[0]); conns[0].Closed || hasType(messages, "join_denied") {
+		t.Errorf("approved client frames = %v, closed = %v, want it to stay connected", messageTypes(messages), conns[0].Closed)
+	}
+	for i := 1; i < len(sessionIDs); i++ {
+		assertDenied(t, uc, roomID, sessionIDs[i], conns[i], "closed")
+	}
+	participants, pending, err := uc.GetParticipants(roomID)
+	if err != nil {
+		t.Fatalf("GetParticipants() error = %v", err)
+	}
+	if len(participants) != 2 || len(pending) != 0 {
+		t.Errorf("GetParticipants() = %d participants, %d pending, want 2 and 0", len(participants), len(pending))
+	}
+}
+
+func TestCancelJoinRequest(t *testing.T) {
+	uc := NewRoomUsecase()
+	roomID, ownerSessionID, sessionIDs, _ := newGatedRoom(t, uc, "guest")
+	ownerConn := attachConn(t, uc, roomID, ownerSessionID)
+	guestConn := attachConn(t, uc, roomID, sessionIDs[0])
+	drainMessages(t, ownerConn)
+
+	if err := uc.CancelJoinRequest(roomID, ownerSessionID); err == nil {
+		t.Errorf("CancelJoinRequest() by an authenticated client should fail")
+	}
+	if err := uc.CancelJoinRequest(roomID, sessionIDs[0]); err != nil {
+		t.Fatalf("CancelJoinRequest() error = %v", err)
+	}
+	if _, err := uc.ValidateSession(roomID, sessionIDs[0]); err == nil {
+		t.Errorf("ValidateSession() after CancelJoinRequest should fail")
+	}
+	drainMessages(t, guestConn)
+	if !guestConn.Closed {
+		t.Errorf("connection should be closed after CancelJoinRequest")
+	}
+	if messages := drainMessages(t, ownerConn); !hasType(messages, "participants_update") {
+		t.Errorf("owner frames = %v, want participants_update", messageTypes(messages))
+	}
+	if err := uc.CancelJoinRequest(roomID, sessionIDs[0]); err == nil {
+		t.Errorf("second CancelJoinRequest() should fail")
+	}
+}
@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/config"
+	"github.com/takaryo1010/OneTimeChat/server/logging"
+)
+
+// DefaultMaxRoomTTL は部屋の有効期限の上限の既定値
+const DefaultMaxRoomTTL = 7 * 24 * time.Hour
+
+// Limits はサーバー全体の上限の設定
+// 設定の再読み込みで丸ごと差し替えるので、取り出した値を書き換えないこと
+type Limits struct {
+	MaxParticipantsCeiling int           // 1部屋あたりの参加者数のサーバー全体の上限
+	MinRoomTTL             time.Duration // 作成時に指定できる有効期限の下限
+	MaxRoomTTL             time.Duration // 作成時に指定できる有効期限の上限
+	JoinCallbackAllowlist  []string      // 参加ポリシー http_callback で問い合わせてよいURLの接頭辞
+}
+
+// DefaultLimits は上限の既定値を返す
+func DefaultLimits() Limits {
+	return Limits{
+		MaxParticipantsCeiling: DefaultMaxParticipantsCeiling,
+		MaxRoomTTL:             DefaultMaxRoomTTL,
+	}
+}
+
+// SetLimits はサーバー全体の上限を差し替える。サーバーの稼働中に呼んでもよい
+func (uc *RoomUsecase) SetLimits(limits Limits) {
+	uc.limits.Store(&limits)
+}
+
+// Limits は現在のサーバー全体の上限を返す
+func (uc *RoomUsecase) Limits() Limits {
+	return *uc.limits.Load()
+}
+
+// SetAllowedOrigins はWebSocket接続を受け付けるオリジンを差し替える。サーバーの稼働中に呼んでもよい
+func (uc *RoomUsecase) SetAllowedOrigins(origins *config.AllowedOrigins) {
+	uc.origins.Store(origins)
+}
+
+// AllowedOrigins は現在の許可されたオリジンを返す。設定されていなければ nil
+func (uc *RoomUsecase) AllowedOrigins() *config.AllowedOrigins {
+	return uc.origins.Load()
+}
+
+// SetTrustedProxies は X-Forwarded-For を信用するプロキシを差し替える。サーバーの稼働中に呼んでもよい
+func (uc *RoomUsecase) SetTrustedProxies(proxies *config.TrustedProxies) {
+	uc.proxies.Store(proxies)
+}
+
+// ClientIP はリクエストを送ったクライアントのIPアドレスを返す
+// SetTrustedProxies が呼ばれるまでは転送ヘッダーを信用せず、接続元のアドレスを使う
+func (uc *RoomUsecase) ClientIP(r *http.Request) string {
+	return uc.proxies.Load().ClientIP(r)
+}
+
+// checkOrigin はWebSocket接続の Origin を確認する
+// Origin ヘッダーのないブラウザ以外のクライアントは受け付ける
+// SetAllowedOrigins が呼ばれるまでは同一オリジンからの接続のみ受け付ける(gorilla/websocket の既定と同じ)
+func (uc *RoomUsecase) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	origins := uc.origins.Load()
+	if origins == nil {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	if err := origins.Check(origin); err != nil {
+		slog.Warn("WebSocket connection rejected", logging.IP(r.RemoteAddr), "error", err)
+		return false
+	}
+	return true
+}
+
+// ErrInvalidSettings は部屋の設定(有効期限・参加者数の上限など)が許可された範囲にない場合のエラー
+var ErrInvalidSettings = errors.New("invalid room settings")
+
+// checkRoomTTL は部屋の有効期限がサーバーで許可された範囲にあるかを確認する
+func checkRoomTTL(expires, now time.Time, limits Limits) error {
+	ttl := expires.Sub(now)
+	if ttl <= 0 || ttl < limits.MinRoomTTL {
+		return fmt.Errorf("%w: expires must be at least %s from now", ErrInvalidSettings, limits.MinRoomTTL)
+	}
+	if limits.MaxRoomTTL > 0 && ttl > limits.MaxRoomTTL {
+		return fmt.Errorf("%w: expires must be within %s from now", ErrInvalidSettings, limits.MaxRoomTTL)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+func TestCreateRoomTTL(t *testing.T) {
+	uc := NewRoomUsecase()
+	uc.SetLimits(Limits{MaxParticipantsCeiling: DefaultMaxParticipantsCeiling, MinRoomTTL: 5 * time.Minute, MaxRoomTTL: 24 * time.Hour})
+
+	tests := []*struct {
+		name    string
+		ttl     time.Duration
+		wantErr bool
+	}{
+		{name: "範囲内", ttl: time.Hour, wantErr: false},
+		{name: "期限切れ", ttl: -time.Minute, wantErr: true},
+		{name: "下限より短い", ttl: time.Minute, wantErr: true},
+		{name: "上限より長い", ttl: 48 * time.Hour, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(tt.ttl)})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateRoom() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
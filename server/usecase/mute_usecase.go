@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"errors"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// ErrMuted はミュート中のクライアントがメッセージを送ろうとした場合のエラー
+var ErrMuted = errors.New("you are muted in this room")
+
+// MuteClient は参加者をミュートする(オーナー・モデレーター用)
+// duration が0なら解除するまで無期限にミュートする
+func (uc *RoomUsecase) MuteClient(roomID, sessionID, clientID string, duration time.Duration) error {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	return muteClient(room, sessionID, clientID, duration)
+}
+
+// UnmuteClient は参加者のミュートを解除する(オーナー・モデレーター用)
+func (uc *RoomUsecase) UnmuteClient(roomID, sessionID, clientID string) error {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	return unmuteClient(room, sessionID, clientID)
+}
+
+// muteClient は MuteClient の本体で、WebSocketの "mute" コマンドからも呼ばれる
+// 呼び出し側で room.Mu をロックしておくこと
+func muteClient(room *model.Room, sessionID, clientID string, duration time.Duration) error {
+	if duration < 0 {
+		return errors.New("duration must not be negative")
+	}
+	target, err := moderationTarget(room, sessionID, clientID)
+	if err != nil {
+		return err
+	}
+
+	stopMuteTimer(target)
+	target.Muted = true
+	target.MutedUntil = time.Time{}
+	if duration > 0 {
+		until := time.Now().Add(duration)
+		target.MutedUntil = until
+		target.MuteTimer = time.AfterFunc(duration, func() {
+			room.Mu.Lock()
+			defer room.Mu.Unlock()
+
+			// 延長・解除された場合や、既に退出している場合は何もしない
+			if !target.Muted || !target.MutedUntil.Equal(until) || findClientBySessionID(room, target.SessionID) != target {
+				return
+			}
+			target.MuteTimer = nil
+			clearMute(room, target)
+		})
+	}
+	broadcastMute(room, target)
+	return nil
+}
+
+// unmuteClient は UnmuteClient の本体で、WebSocketの "unmute" コマンドからも呼ばれる
+// 呼び出し側で room.Mu をロックしておくこと
+func unmuteClient(room *model.Room, sessionID, clientID string) error {
+	target, err := moderationTarget(room, sessionID, clientID)
+	if err != nil {
+		return err
+	}
+	if !target.Muted {
+		return errors.New("client is not muted")
+	}
+	stopMuteTimer(target)
+	clearMute(room, target)
+	return nil
+}
+
+// moderationTarget はミュートの操作権限を確認し、対象の参加者を返す
+// 呼び出し側で room.Mu をロックしておくこと
+func moderationTarget(room *model.Room, sessionID, clientID string) (*model.Client, error) {
+	actor, err := authorize(room, sessionID, PermMute)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range room.AuthenticatedClients {
+		if c.ClientID != clientID {
+			continue
+		}
+		if !outranks(actor, c) {
+			return nil, errors.New("you cannot mute this participant")
+		}
+		return c, nil
+	}
+	return nil, errors.New("client not found in the room")
+}
+
+// isMuted はクライアントが現在ミュートされているかを返す
+func isMuted(client *model.Client, now time.Time) bool {
+	if !client.Muted {
+		return false
+	}
+	return client.MutedUntil.IsZero() || now.Before(client.MutedUntil)
+}
+
+// clearMute はミュートを解除して部屋全体に通知する
+// 呼び出し側で room.Mu をロックしておくこと
+func clearMute(room *model.Room, client *model.Client) {
+	client.Muted = false
+	client.MutedUntil = time.Time{}
+	broadcastMute(room, client)
+}
+
+// stopMuteTimer は予約済みのミュート期限切れの通知を取り消す
+// 呼び出し側で room.Mu をロックしておくこと
+func stopMuteTimer(client *model.Client) {
+	if client.MuteTimer != nil {
+		client.MuteTimer.Stop()
+		client.MuteTimer = nil
+	}
+}
+
+// broadcastMute はクライアントのミュート状態を部屋全体に通知する
+// Sentence は "muted" か "unmuted" で、期限付きのミュートなら Until に期限が入る
+// 呼び出し側で room.Mu をロックしておくこと
+func broadcastMute(room *model.Room, client *model.Client) {
+	status := "unmuted"
+	if client.Muted {
+		status = "muted"
+	}
+	message := &model.Message{
+		RoomID:    room.ID,
+		Sentence:  status,
+		Sender:    client.Name,
+		Timestamp: time.Now().Unix(),
+		Type:      "mute_update",
+
+		SenderID:   client.ClientID,
+		SenderRole: client.Role,
+	}
+	if client.Muted && !client.MutedUntil.IsZero() {
+		message.Until = client.MutedUntil.Unix()
+	}
+	if event := recordEvent(room, message, false); event != nil {
+		sendToAll(room, event)
+	}
+}
+
+// mutedUntilUnix はレスポンス用にミュートの期限をUNIX時間で返す(無期限・ミュートなしは0)
+func mutedUntilUnix(client *model.Client) int64 {
+	if !client.Muted || client.MutedUntil.IsZero() {
+		return 0
+	}
+	return client.MutedUntil.Unix()
+}
@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+func TestMuteClient(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	memberSessionID, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "member"})
+	if err != nil {
+		t.Fatalf("JoinRoom() error = %v", err)
+	}
+	participants, _, err := uc.GetParticipants(room.ID)
+	if err != nil {
+		t.Fatalf("GetParticipants() error = %v", err)
+	}
+	ownerID, memberID := participants[0].ClientID, participants[1].ClientID
+
+	if err := uc.MuteClient(room.ID, memberSessionID, ownerID, 0); err == nil {
+		t.Errorf("MuteClient() by a member should fail")
+	}
+
+	tests := []*struct {
+		name     string
+		mute     func() error
+		wait     time.Duration
+		wantErr  error
+		wantMute bool
+	}{
+		{
+			name:     "indefinite mute",
+			mute:     func() error { return uc.MuteClient(room.ID, ownerSessionID, memberID, 0) },
+			wantErr:  ErrMuted,
+			wantMute: true,
+		},
+		{
+			name:    "unmute",
+			mute:    func() error { return uc.UnmuteClient(room.ID, ownerSessionID, memberID) },
+			wantErr: nil,
+		},
+		{
+			name:    "timed mute expires",
+			mute:    func() error { return uc.MuteClient(room.ID, ownerSessionID, memberID, 20*time.Millisecond) },
+			wait:    50 * time.Millisecond,
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.mute(); err != nil {
+				t.Fatalf("mute error = %v", err)
+			}
+			time.Sleep(tt.wait)
+
+			err := uc.SendMessage(room.ID, memberSessionID, "hello")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SendMessage() error = %v, want %v", err, tt.wantErr)
+			}
+			participants, _, err := uc.GetParticipants(room.ID)
+			if err != nil {
+				t.Fatalf("GetParticipants() error = %v", err)
+			}
+			if participants[1].Muted != tt.wantMute {
+				t.Errorf("Muted = %v, want %v", participants[1].Muted, tt.wantMute)
+			}
+		})
+	}
+}
+
+func TestMuteCommandOverWebSocket(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	if _, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "member"}); err != nil {
+		t.Fatalf("JoinRoom() error = %v", err)
+	}
+	participants, _, err := uc.GetParticipants(room.ID)
+	if err != nil {
+		t.Fatalf("GetParticipants() error = %v", err)
+	}
+	memberID := participants[1].ClientID
+	ownerConn := attachConn(t, uc, room.ID, ownerSessionID)
+
+	tests := []*struct {
+		name      string
+		duration  int
+		wantMuted bool
+		wantError bool // 送信元の接続に "error" フレームが返ること
+	}{
+		{name: "negative duration is rejected", duration: -5, wantMuted: false, wantError: true},
+		{name: "timed mute", duration: 60, wantMuted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drainMessages(t, ownerConn)
+			frame, err := json.Marshal(map[string]any{"type": "mute", "client_id": memberID, "duration": tt.duration})
+			if err != nil {
+				t.Fatal(err)
+			}
+			uc.broadcastToRoom(room.ID, frame, ownerSessionID, ownerConn.ID)
+
+			participants, _, err := uc.GetParticipants(room.ID)
+			if err != nil {
+				t.Fatalf("GetParticipants() error = %v", err)
+			}
+			if participants[1].Muted != tt.wantMuted {
+				t.Errorf("Muted = %v, want %v", participants[1].Muted, tt.wantMuted)
+			}
+			gotError := false
+			for _, m := range drainMessages(t, ownerConn) {
+				if m.Type == "error" && m.Code == "command_failed" {
+					gotError = true
+				}
+			}
+			if gotError != tt.wantError {
+				t.Errorf("error frame = %v, want %v", gotError, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/logging"
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// successionGraceSeconds が未設定の場合の猶予
+const defaultSuccessionGrace = 60 * time.Second
+
+// TransferOwnership は現在のオーナーが別の認証済みクライアントにオーナー権限を渡す
+func (uc *RoomUsecase) TransferOwnership(roomID, ownerSessionID, newOwnerClientID string) error {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if _, err := authorize(room, ownerSessionID, PermTransferOwnership); err != nil {
+		return err
+	}
+
+	var newOwner *model.Client
+	for _, c := range room.AuthenticatedClients {
+		if c.ClientID == newOwnerClientID {
+			newOwner = c
+			break
+		}
+	}
+	if newOwner == nil {
+		return errors.New("client not found in the room")
+	}
+	if newOwner.SessionID == ownerSessionID {
+		return errors.New("you are already the owner of this room")
+	}
+
+	setOwner(room, newOwner)
+	return nil
+}
+
+// setOwner はオーナーを切り替え、部屋全体に通知する
+// 元のオーナーはメンバーになる
+// 呼び出し側で room.Mu をロックしておくこと
+func setOwner(room *model.Room, newOwner *model.Client) {
+	stopSuccessionTimer(room)
+	if oldOwner := findClientBySessionID(room, room.OwnerSessionID); oldOwner != nil {
+		oldOwner.Role = model.RoleMember
+	}
+	newOwner.Role = model.RoleOwner
+	room.OwnerSessionID = newOwner.SessionID
+	room.Owner = newOwner.Name
+
+	slog.Info("owner changed", logging.KeyRoomID, room.ID, logging.KeyClientID, newOwner.ClientID)
+
+	event := recordEvent(room, &model.Message{
+		RoomID:    room.ID,
+		Sentence:  newOwner.ClientID,
+		Sender:    newOwner.Name,
+		Timestamp: time.Now().Unix(),
+		Type:      "owner_changed",
+	}, false)
+	if event != nil {
+		sendToAll(room, event)
+	}
+}
+
+// promoteSuccessor は在室時間が最も長い参加者をオーナーに昇格させる
+// オンラインの参加者を優先し、いなければオフラインの参加者から選ぶ
+// 候補がいなければ false を返す
+// 呼び出し側で room.Mu をロックしておくこと
+func promoteSuccessor(room *model.Room) bool {
+	var successor *model.Client
+	for _, online := range []bool{true, false} {
+		for _, c := range room.AuthenticatedClients {
+			if c.SessionID == room.OwnerSessionID || isOnline(c) != online {
+				continue
+			}
+			if successor == nil || c.JoinedAt.Before(successor.JoinedAt) {
+				successor = c
+			}
+		}
+		if successor != nil {
+			break
+		}
+	}
+	if successor == nil {
+		return false
+	}
+	setOwner(room, successor)
+	return true
+}
+
+// scheduleSuccession はオーナーの最後の接続が切れたときに呼び、
+// 猶予時間が過ぎてもオーナーがオフラインのままなら後継者を昇格させる
+// 呼び出し側で room.Mu をロックしておくこと
+func scheduleSuccession(room *model.Room) {
+	if !room.AutoSuccession {
+		return
+	}
+	stopSuccessionTimer(room)
+
+	grace := time.Duration(room.SuccessionGraceSeconds) * time.Second
+	if grace <= 0 {
+		grace = defaultSuccessionGrace
+	}
+	ownerSessionID := room.OwnerSessionID
+	room.SuccessionTimer = time.AfterFunc(grace, func() {
+		room.Mu.Lock()
+		defer room.Mu.Unlock()
+
+		room.SuccessionTimer = nil
+		if !room.AutoSuccession || room.OwnerSessionID != ownerSessionID {
+			return
+		}
+		owner := findClientBySessionID(room, ownerSessionID)
+		if owner != nil && isOnline(owner) {
+			return
+		}
+		promoteSuccessor(room)
+	})
+}
+
+// stopSuccessionTimer は予約済みの自動引き継ぎを取り消す
+// 呼び出し側で room.Mu をロックしておくこと
+func stopSuccessionTimer(room *model.Room) {
+	if room.SuccessionTimer != nil {
+		room.SuccessionTimer.Stop()
+		room.SuccessionTimer = nil
+	}
+}
@@ -0,0 +1,166 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// newOwnedRoom は部屋を作り、members の名前で認証済みの参加者を順に追加する
+// 部屋ID・オーナーのセッションID・参加者のセッションIDとクライアントIDを返す
+func newOwnedRoom(t *testing.T, uc *RoomUsecase, settings *model.Room, members ...string) (roomID, ownerSessionID string, sessionIDs, clientIDs []string) {
+	t.Helper()
+	settings.Name, settings.Owner, settings.Expires = "room", "owner", time.Now().Add(time.Hour)
+	room, ownerSessionID, err := uc.CreateRoom(settings)
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	for _, name := range members {
+		sessionID, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: name})
+		if err != nil {
+			t.Fatalf("JoinRoom() error = %v", err)
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+		// 在室時間で後継者を選ぶので、参加時刻が同じにならないようにする
+		time.Sleep(time.Millisecond)
+	}
+	participants, _, err := uc.GetParticipants(room.ID)
+	if err != nil {
+		t.Fatalf("GetParticipants() error = %v", err)
+	}
+	for _, p := range participants[1:] {
+		clientIDs = append(clientIDs, p.ClientID)
+	}
+	return room.ID, ownerSessionID, sessionIDs, clientIDs
+}
+
+// currentOwner はオーナーの表示名を返す(オーナーがいなければ空文字列)
+func currentOwner(t *testing.T, uc *RoomUsecase, roomID string) string {
+	t.Helper()
+	participants, _, err := uc.GetParticipants(roomID)
+	if err != nil {
+		t.Fatalf("GetParticipants() error = %v", err)
+	}
+	for _, p := range participants {
+		if p.IsOwner {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+func TestTransferOwnership(t *testing.T) {
+	uc := NewRoomUsecase()
+	roomID, ownerSessionID, sessionIDs, clientIDs := newOwnedRoom(t, uc, &model.Room{}, "alice", "bob")
+	bobConn := attachConn(t, uc, roomID, sessionIDs[1])
+	drainMessages(t, bobConn)
+
+	if err := uc.TransferOwnership(roomID, sessionIDs[0], clientIDs[1]); err == nil {
+		t.Errorf("TransferOwnership() by a member should fail")
+	}
+	if err := uc.TransferOwnership(roomID, ownerSessionID, "missing"); err == nil {
+		t.Errorf("TransferOwnership() to an unknown client should fail")
+	}
+	if err := uc.TransferOwnership(roomID, ownerSessionID, clientIDs[0]); err != nil {
+		t.Fatalf("TransferOwnership() error = %v", err)
+	}
+
+	if got := currentOwner(t, uc, roomID); got != "alice" {
+		t.Errorf("owner = %q, want %q", got, "alice")
+	}
+	messages := drainMessages(t, bobConn)
+	if !hasType(messages, "owner_changed") {
+		t.Errorf("frames = %v, want owner_changed", messageTypes(messages))
+	}
+
+	room := uc.RoomManager.Rooms[roomID]
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+	if owner := findClientBySessionID(room, ownerSessionID); owner.Role != model.RoleMember {
+		t.Errorf("old owner role = %v, want %v", owner.Role, model.RoleMember)
+	}
+	if alice := findClientBySessionID(room, sessionIDs[0]); alice.Role != model.RoleOwner {
+		t.Errorf("new owner role = %v, want %v", alice.Role, model.RoleOwner)
+	}
+	for _, perm := range rolePermissions[model.RoleOwner] {
+		if _, err := authorize(room, sessionIDs[0], perm); err != nil {
+			t.Errorf("new owner authorize(%v) error = %v", perm, err)
+		}
+		_, err := authorize(room, ownerSessionID, perm)
+		if want := hasPermission(model.RoleMember, perm); (err == nil) != want {
+			t.Errorf("old owner authorize(%v) error = %v, want allowed = %v", perm, err, want)
+		}
+	}
+}
+
+func TestSuccessionOnLeave(t *testing.T) {
+	tests := []*struct {
+		name           string
+		autoSuccession bool
+		online         []bool // 参加者ごとにオンラインにするか
+		wantOwner      string
+	}{
+		{name: "online participant is preferred", autoSuccession: true, online: []bool{false, true}, wantOwner: "bob"},
+		{name: "longest tenure among online participants", autoSuccession: true, online: []bool{true, true}, wantOwner: "alice"},
+		{name: "longest tenure when everyone is offline", autoSuccession: true, online: []bool{false, false}, wantOwner: "alice"},
+		{name: "no succession when disabled", autoSuccession: false, online: []bool{true, true}, wantOwner: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := NewRoomUsecase()
+			roomID, ownerSessionID, sessionIDs, _ := newOwnedRoom(t, uc, &model.Room{AutoSuccession: tt.autoSuccession}, "alice", "bob")
+			for i, online := range tt.online {
+				if online {
+					attachConn(t, uc, roomID, sessionIDs[i])
+				}
+			}
+
+			if err := uc.LeaveRoom(roomID, ownerSessionID); err != nil {
+				t.Fatalf("LeaveRoom() error = %v", err)
+			}
+			if got := currentOwner(t, uc, roomID); got != tt.wantOwner {
+				t.Errorf("owner = %q, want %q", got, tt.wantOwner)
+			}
+		})
+	}
+}
+
+func TestSuccessionAfterGrace(t *testing.T) {
+	tests := []*struct {
+		name      string
+		reconnect bool // 猶予時間内にオーナーが再接続する
+		wantOwner string
+	}{
+		{name: "successor is promoted after the grace period", reconnect: false, wantOwner: "alice"},
+		{name: "reconnecting owner cancels the succession", reconnect: true, wantOwner: "owner"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			uc := NewRoomUsecase()
+			roomID, ownerSessionID, _, _ := newOwnedRoom(t, uc, &model.Room{AutoSuccession: true, SuccessionGraceSeconds: 1}, "alice")
+			ownerConn := attachConn(t, uc, roomID, ownerSessionID)
+
+			detachConn(t, uc, roomID, ownerSessionID, ownerConn)
+			if got := currentOwner(t, uc, roomID); got != "owner" {
+				t.Fatalf("owner right after disconnecting = %q, want %q", got, "owner")
+			}
+			if tt.reconnect {
+				attachConn(t, uc, roomID, ownerSessionID)
+			}
+
+			time.Sleep(1500 * time.Millisecond)
+			if got := currentOwner(t, uc, roomID); got != tt.wantOwner {
+				t.Errorf("owner = %q, want %q", got, tt.wantOwner)
+			}
+			room := uc.RoomManager.Rooms[roomID]
+			room.Mu.Lock()
+			defer room.Mu.Unlock()
+			if room.SuccessionTimer != nil {
+				t.Errorf("succession timer should be cleared")
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package usecase
+
+import (
+	crand "crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/takaryo1010/OneTimeChat/server/metrics"
+)
+
+var (
+	// ErrInvalidPassword はルームのパスワードが一致しない場合のエラー
+	ErrInvalidPassword = errors.New("invalid room password")
+	// ErrTooManyAttempts はパスワードの試行回数が上限に達した場合のエラー
+	ErrTooManyAttempts = errors.New("too many password attempts, try again later")
+)
+
+const (
+	maxPasswordLength = 128
+
+	// argon2id のパラメータ (OWASP推奨の最小構成)
+	argon2Time    = 2
+	argon2Memory  = 19 * 1024 // KiB
+	argon2Threads = 1
+	argon2KeyLen  = 32
+	saltLength    = 16
+
+	// 総当たり対策: 期間内の失敗回数の上限
+	// 部屋単位の上限は、その部屋のパスワードをまだ通ったことのないIPからの試行だけに適用する
+	// (知らないIPから上限まで間違えられても、一度参加できたIPは締め出されない)
+	passwordAttemptWindow   = 10 * time.Minute
+	passwordTrustDuration   = 24 * time.Hour
+	maxFailuresPerIP        = 5
+	maxFailuresPerRoom      = 30
+	passwordThrottleKeyRoom = "room:"
+	passwordThrottleKeyIP   = "ip:"
+
+	// 同時に実行する argon2id の上限 (argon2Memory × この数 がメモリ使用量の上限になる)
+	maxConcurrentArgon2 = 4
+)
+
+// hashPassword はランダムなソルトを生成し、argon2id でパスワードをハッシュ化する
+func hashPassword(password string) (hash, salt []byte, err error) {
+	if utf8.RuneCountInString(password) > maxPasswordLength {
+		return nil, nil, fmt.Errorf("%w: password must be at most %d characters", ErrInvalidSettings, maxPasswordLength)
+	}
+	salt = make([]byte, saltLength)
+	if _, err := crand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+	return deriveKey(password, salt), salt, nil
+}
+
+// verifyPassword はパスワードを定数時間で照合する
+func verifyPassword(password string, hash, salt []byte) bool {
+	return subtle.ConstantTimeCompare(deriveKey(password, salt), hash) == 1
+}
+
+// argon2Slots は同時に実行する argon2id の数を抑えるセマフォ
+// 1回あたり argon2Memory のメモリを使うので、未認証のリクエストを大量に送られてもメモリを使い切らないようにする
+var argon2Slots = make(chan struct{}, maxConcurrentArgon2)
+
+func deriveKey(password string, salt []byte) []byte {
+	argon2Slots <- struct{}{}
+	defer func() { <-argon2Slots }()
+	return argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+}
+
+// attemptThrottle はキーごとに一定期間内の試行回数を数える
+// 照合の前に試行を数え、成功した場合だけ取り消すので、並行したリクエストでも上限を超えられない
+// 成功したキーは trustFor の間だけ覚えておく
+type attemptThrottle struct {
+	attempts  map[string][]time.Time
+	passed    map[string]time.Time // キー -> 最後に成功した時刻
+	window    time.Duration
+	trustFor  time.Duration
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+func newAttemptThrottle(window, trustFor time.Duration) *attemptThrottle {
+	return &attemptThrottle{
+		attempts: make(map[string][]time.Time),
+		passed:   make(map[string]time.Time),
+		window:   window,
+		trustFor: trustFor,
+	}
+}
+
+// attempt はすべてのキーの試行回数がそれぞれの上限未満であれば、各キーに試行を1回記録して true を返す
+// 1つでも上限に達していれば何も記録せずに false を返す
+func (t *attemptThrottle) attempt(now time.Time, limits map[string]int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.lastSweep) >= t.window {
+		t.sweep(now)
+	}
+	for key, limit := range limits {
+		if len(t.recent(key, now)) >= limit {
+			return false
+		}
+	}
+	for key := range limits {
+		t.attempts[key] = append(t.attempts[key], now)
+	}
+	return true
+}
+
+// refund は attempt で at に記録した試行を取り消す
+func (t *attemptThrottle) refund(at time.Time, keys ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range keys {
+		times := t.attempts[key]
+		for i, v := range times {
+			if v.Equal(at) {
+				t.attempts[key] = append(times[:i], times[i+1:]...)
+				break
+			}
+		}
+		if len(t.attempts[key]) == 0 {
+			delete(t.attempts, key)
+		}
+	}
+}
+
+// markPassed はキーが成功したことを記録する
+func (t *attemptThrottle) markPassed(key string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.passed[key] = now
+}
+
+// hasPassed はキーが trustFor 以内に成功していれば true を返す
+func (t *attemptThrottle) hasPassed(key string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	at, ok := t.passed[key]
+	return ok && now.Sub(at) < t.trustFor
+}
+
+// recent は期間内の試行だけを残して返す
+// 呼び出し側で t.mu をロックしておくこと
+func (t *attemptThrottle) recent(key string, now time.Time) []time.Time {
+	kept := t.attempts[key][:0]
+	for _, at := range t.attempts[key] {
+		if now.Sub(at) < t.window {
+			kept = append(kept, at)
+		}
+	}
+	if len(kept) == 0 {
+		delete(t.attempts, key)
+		return nil
+	}
+	t.attempts[key] = kept
+	return kept
+}
+
+// sweep は期間内の試行が残っていないキーを取り除く
+// 呼び出し側で t.mu をロックしておくこと
+func (t *attemptThrottle) sweep(now time.Time) {
+	for key := range t.attempts {
+		t.recent(key, now)
+	}
+	for key, at := range t.passed {
+		if now.Sub(at) >= t.trustFor {
+			delete(t.passed, key)
+		}
+	}
+	t.lastSweep = now
+}
+
+// checkRoomPassword は部屋単位・IP単位の試行回数を確認したうえでパスワードを照合する
+// 試行は照合の前に数え、正しいパスワードだった場合は取り消す
+// clientIP は信用するプロキシを考慮して求めたアドレスを渡すこと
+func (uc *RoomUsecase) checkRoomPassword(roomID, clientIP, password string, hash, salt []byte) error {
+	now := time.Now()
+	roomKey := passwordThrottleKeyRoom + roomID
+	ipKey := passwordThrottleKeyIP + clientIP
+	passedKey := roomKey + "/" + ipKey
+	limits := map[string]int{ipKey: maxFailuresPerIP}
+	if !uc.passwordThrottle.hasPassed(passedKey, now) {
+		limits[roomKey] = maxFailuresPerRoom
+	}
+	if !uc.passwordThrottle.attempt(now, limits) {
+		metrics.RateLimitHits.WithLabelValues("password").Inc()
+		return ErrTooManyAttempts
+	}
+
+	if !verifyPassword(password, hash, salt) {
+		return ErrInvalidPassword
+	}
+	for key := range limits {
+		uc.passwordThrottle.refund(now, key)
+	}
+	uc.passwordThrottle.markPassed(passedKey, now)
+	return nil
+}
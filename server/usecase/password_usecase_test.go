@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+func TestJoinRoomWithPassword(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, _, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Password: "secret", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	if !room.HasPassword {
+		t.Fatalf("CreateRoom() HasPassword = false, want true")
+	}
+
+	if _, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "guest", Password: "secret", ClientIP: "192.0.2.1"}); err != nil {
+		t.Errorf("JoinRoom() with the right password error = %v", err)
+	}
+
+	// 同じIPから上限まで間違えると、正しいパスワードでも拒否される
+	for i := 0; i < maxFailuresPerIP; i++ {
+		_, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "guest", Password: "wrong", ClientIP: "192.0.2.2"})
+		if !errors.Is(err, ErrInvalidPassword) {
+			t.Fatalf("JoinRoom() with a wrong password error = %v, want %v", err, ErrInvalidPassword)
+		}
+	}
+	_, err = uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "guest", Password: "secret", ClientIP: "192.0.2.2"})
+	if !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("JoinRoom() after too many failures error = %v, want %v", err, ErrTooManyAttempts)
+	}
+
+	// 別のIPからは引き続き参加できる
+	if _, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "guest", Password: "secret", ClientIP: "192.0.2.3"}); err != nil {
+		t.Errorf("JoinRoom() from another IP error = %v", err)
+	}
+}
+
+func TestJoinRoomPasswordParallel(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, _, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Password: "secret", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+
+	// 並行して間違えても、照合まで進めるのは上限の回数まで
+	const attempts = 20
+	errs := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "guest", Password: "wrong", ClientIP: "192.0.2.10"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	invalid := 0
+	for err := range errs {
+		switch {
+		case errors.Is(err, ErrInvalidPassword):
+			invalid++
+		case errors.Is(err, ErrTooManyAttempts):
+		default:
+			t.Errorf("JoinRoom() error = %v", err)
+		}
+	}
+	if invalid != maxFailuresPerIP {
+		t.Errorf("%d attempts were verified, want %d", invalid, maxFailuresPerIP)
+	}
+}
+
+func TestJoinRoomPasswordRoomBudget(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, _, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Password: "secret", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	if _, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "member", Password: "secret", ClientIP: "192.0.2.1"}); err != nil {
+		t.Fatalf("JoinRoom() error = %v", err)
+	}
+
+	// 複数のIPから部屋単位の上限まで間違える
+	for i := 0; i < maxFailuresPerRoom; i++ {
+		ip := fmt.Sprintf("198.51.100.%d", i/maxFailuresPerIP)
+		_, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "attacker", Password: "wrong", ClientIP: ip})
+		if !errors.Is(err, ErrInvalidPassword) {
+			t.Fatalf("JoinRoom() with a wrong password error = %v, want %v", err, ErrInvalidPassword)
+		}
+	}
+
+	tests := []*struct {
+		name    string
+		ip      string
+		wantErr error
+	}{
+		{name: "new IP is throttled", ip: "192.0.2.2", wantErr: ErrTooManyAttempts},
+		{name: "IP that already joined is not locked out", ip: "192.0.2.1", wantErr: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "member", Password: "secret", ClientIP: tt.ip})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("JoinRoom() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAttemptThrottle(t *testing.T) {
+	now := time.Now()
+	throttle := newAttemptThrottle(time.Minute, time.Hour)
+	limits := map[string]int{"ip:a": 2}
+
+	tests := []*struct {
+		name   string
+		at     time.Time
+		refund bool
+		want   bool
+	}{
+		{name: "1回目", at: now, want: true},
+		{name: "成功した試行は取り消す", at: now.Add(time.Second), refund: true, want: true},
+		{name: "2回目", at: now.Add(2 * time.Second), want: true},
+		{name: "上限に達した", at: now.Add(3 * time.Second), want: false},
+		{name: "期間が過ぎれば試行できる", at: now.Add(2 * time.Minute), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := throttle.attempt(tt.at, limits); got != tt.want {
+				t.Fatalf("attempt() = %v, want %v", got, tt.want)
+			}
+			if tt.refund {
+				throttle.refund(tt.at, "ip:a")
+			}
+		})
+	}
+
+	// 古いキーは次の試行のときにまとめて取り除かれる
+	throttle.attempt(now.Add(10*time.Minute), map[string]int{"ip:b": 1})
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+	if _, ok := throttle.attempts["ip:a"]; ok || len(throttle.attempts) != 1 {
+		t.Errorf("attempts = %v, want only ip:b", throttle.attempts)
+	}
+}
@@ -0,0 +1,120 @@
+package usecase
+
+import (
+	"errors"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// Permission は部屋の中で行える操作を表す
+type Permission string
+
+const (
+	PermSendMessage       Permission = "send_message"       // チャットメッセージの送信
+	PermReact             Permission = "react"              // メッセージへのリアクション
+	PermApprove           Permission = "approve"            // 参加待ちのクライアントの承認
+	PermKick              Permission = "kick"               // 参加者のキック
+	PermMute              Permission = "mute"               // 参加者のミュート
+	PermUpdateSettings    Permission = "update_settings"    // 部屋の設定変更
+	PermDeleteRoom        Permission = "delete_room"        // 部屋の削除
+	PermTransferOwnership Permission = "transfer_ownership" // オーナー権限の譲渡
+	PermManageRoles       Permission = "manage_roles"       // 参加者のロール変更
+	PermManageInvites     Permission = "manage_invites"     // 招待の発行・一覧・取り消し
+)
+
+// rolePermissions はロールごとに許可されている操作
+var rolePermissions = map[model.Role][]Permission{
+	model.RoleOwner: {
+		PermSendMessage, PermReact, PermApprove, PermKick, PermMute,
+		PermUpdateSettings, PermDeleteRoom, PermTransferOwnership, PermManageRoles, PermManageInvites,
+	},
+	model.RoleModerator: {PermSendMessage, PermReact, PermApprove, PermKick, PermMute, PermManageInvites},
+	model.RoleMember:    {PermSendMessage, PermReact},
+	model.RoleViewer:    {PermReact}, // 発言はできないが、リアクションで反応はできる
+}
+
+// roleRanks はロールの強さ。自分より弱いロールの参加者にしかキック等の操作はできない
+var roleRanks = map[model.Role]int{
+	model.RoleOwner:     3,
+	model.RoleModerator: 2,
+	model.RoleMember:    1,
+	model.RoleViewer:    0,
+}
+
+// hasPermission はロールに操作が許可されているかを返す
+func hasPermission(role model.Role, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// outranks は actor が target に対してキック等の操作をできるかを返す
+func outranks(actor, target *model.Client) bool {
+	return roleRanks[actor.Role] > roleRanks[target.Role]
+}
+
+// authorize はセッションIDに対応する認証済みクライアントを探し、操作が許可されているか確認する
+// 呼び出し側で room.Mu をロックしておくこと
+func authorize(room *model.Room, sessionID string, perm Permission) (*model.Client, error) {
+	for _, c := range room.AuthenticatedClients {
+		if c.SessionID == sessionID {
+			if !hasPermission(c.Role, perm) {
+				return nil, errors.New("you do not have permission to do this")
+			}
+			return c, nil
+		}
+	}
+	return nil, errors.New("you are not a participant of this room")
+}
+
+// SetRole は参加者のロールを変更する(オーナー専用)
+// オーナーへの変更は TransferOwnership を使う
+func (uc *RoomUsecase) SetRole(roomID, sessionID, clientID string, role model.Role) error {
+	if role != model.RoleModerator && role != model.RoleMember && role != model.RoleViewer {
+		return errors.New("role must be one of moderator, member or viewer")
+	}
+
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	actor, err := authorize(room, sessionID, PermManageRoles)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range room.AuthenticatedClients {
+		if c.ClientID != clientID {
+			continue
+		}
+		if !outranks(actor, c) {
+			return errors.New("you cannot change the role of this participant")
+		}
+		c.Role = role
+
+		event := recordEvent(room, &model.Message{
+			RoomID:    room.ID,
+			Sentence:  string(role),
+			Sender:    c.Name,
+			Timestamp: time.Now().Unix(),
+			Type:      "role_changed",
+		}, false)
+		if event != nil {
+			sendToAll(room, event)
+		}
+		return nil
+	}
+
+	return errors.New("client not found in the room")
+}
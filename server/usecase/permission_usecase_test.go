@@ -0,0 +1,35 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+func Test_hasPermission(t *testing.T) {
+	tests := []*struct {
+		name string
+		role model.Role
+		perm Permission
+		want bool
+	}{
+		{name: "owner can delete the room", role: model.RoleOwner, perm: PermDeleteRoom, want: true},
+		{name: "moderator can approve", role: model.RoleModerator, perm: PermApprove, want: true},
+		{name: "moderator can kick", role: model.RoleModerator, perm: PermKick, want: true},
+		{name: "moderator can mute", role: model.RoleModerator, perm: PermMute, want: true},
+		{name: "moderator cannot delete the room", role: model.RoleModerator, perm: PermDeleteRoom, want: false},
+		{name: "moderator cannot transfer ownership", role: model.RoleModerator, perm: PermTransferOwnership, want: false},
+		{name: "member can send messages", role: model.RoleMember, perm: PermSendMessage, want: true},
+		{name: "member cannot kick", role: model.RoleMember, perm: PermKick, want: false},
+		{name: "viewer cannot send messages", role: model.RoleViewer, perm: PermSendMessage, want: false},
+		{name: "viewer can react", role: model.RoleViewer, perm: PermReact, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasPermission(tt.role, tt.perm); got != tt.want {
+				t.Errorf("hasPermission(%v, %v) = %v, want %v", tt.role, tt.perm, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,68 +1,132 @@
 package usecase
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/takaryo1010/OneTimeChat/server/config"
+	"github.com/takaryo1010/OneTimeChat/server/metrics"
 	"github.com/takaryo1010/OneTimeChat/server/model"
+	"github.com/takaryo1010/OneTimeChat/server/validator"
 )
 
+// WebSocketSubprotocol はサーバーが応答するサブプロトコル名
+const WebSocketSubprotocol = "onetimechat"
+
 type RoomUsecase struct {
 	RoomManager *model.RoomManager
 	upgrader    websocket.Upgrader
+	tokenSecret []byte // Bearerトークンの署名鍵
+
+	passwordThrottle *attemptThrottle // パスワード総当たり対策
+
+	limits  atomic.Pointer[Limits]                // サーバー全体の上限(設定の再読み込みで差し替える)
+	origins atomic.Pointer[config.AllowedOrigins] // WebSocket接続を受け付けるオリジン
+	proxies atomic.Pointer[config.TrustedProxies] // X-Forwarded-For を信用するプロキシ
 }
 
 // NewRoomUsecase creates a new RoomUsecase instance.
 func NewRoomUsecase() *RoomUsecase {
-	return &RoomUsecase{
+	uc := &RoomUsecase{
 		RoomManager: &model.RoomManager{
 			Rooms:           make(map[string]*model.Room),
 			ExpireSortRooms: []*model.Room{},
+			Sessions:        NewSessionRegistry(),
 			Mu:              sync.Mutex{},
 		},
 		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool { return true },
+			// トークンを "bearer.<token>" サブプロトコルで渡すクライアントはこちらも合わせて指定する
+			Subprotocols: []string{WebSocketSubprotocol},
 		},
+		tokenSecret:      newTokenSecret(),
+		passwordThrottle: newAttemptThrottle(passwordAttemptWindow, passwordTrustDuration),
 	}
+	uc.upgrader.CheckOrigin = uc.checkOrigin
+	uc.SetLimits(DefaultLimits())
+	return uc
 }
 
 // CreateRoom 新しい部屋を作る
 func (uc *RoomUsecase) CreateRoom(room *model.Room) (*model.ResponseRoom, string, error) {
-	uc.RoomManager.Mu.Lock()
-	defer uc.RoomManager.Mu.Unlock()
-
-	// セッションIDの生成
-	sessionID, err := GenerateSessionID()
+	roomName, err := validator.NormalizeRoomName(room.Name)
+	if err != nil {
+		return nil, "", err
+	}
+	ownerName, err := validator.NormalizeDisplayName(room.Owner)
 	if err != nil {
 		return nil, "", err
 	}
+	limits := uc.Limits()
+	if err := checkRoomTTL(room.Expires, time.Now(), limits); err != nil {
+		return nil, "", err
+	}
+	maxParticipants, err := resolveMaxParticipants(room.MaxParticipants, limits.MaxParticipantsCeiling)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := validateSlowMode(room.SlowModeSeconds); err != nil {
+		return nil, "", err
+	}
+
+	// パスワードはハッシュのみ保存する(ハッシュ化は重いのでロックの外で行う)
+	var passwordHash, passwordSalt []byte
+	if room.Password != "" {
+		hash, salt, err := hashPassword(room.Password)
+		if err != nil {
+			return nil, "", err
+		}
+		passwordHash, passwordSalt = hash, salt
+	}
+
+	uc.RoomManager.Mu.Lock()
+	defer uc.RoomManager.Mu.Unlock()
 
 	roomID := generateRoomID(uc.RoomManager) // 任意のID生成関数を使用
 	room = &model.Room{
 		ID:                     roomID,
-		Name:                   room.Name,
-		Owner:                  room.Owner,
+		Name:                   roomName,
+		Owner:                  ownerName,
 		Expires:                room.Expires,
 		RequiresAuth:           room.RequiresAuth,
-		OwnerSessionID:         sessionID,
+		PasswordHash:           passwordHash,
+		PasswordSalt:           passwordSalt,
+		MaxParticipants:        maxParticipants,
+		Locked:                 room.Locked,
+		SlowModeSeconds:        room.SlowModeSeconds,
+		AnnouncementOnly:       room.AnnouncementOnly,
+		AutoSuccession:         room.AutoSuccession,
+		SuccessionGraceSeconds: room.SuccessionGraceSeconds,
 		UnauthenticatedClients: []*model.Client{},
 		AuthenticatedClients:   []*model.Client{}, // 初期化
 		Mu:                     sync.Mutex{},
 	}
 
+	// オーナーのセッションを発行
+	clientID := GeneratedClientID(uc.RoomManager)
+	session, err := issueSession(uc.RoomManager.Sessions, room, clientID)
+	if err != nil {
+		return nil, "", err
+	}
+	room.OwnerSessionID = session.ID
+
 	// 部屋を作成し、マネージャーに登録
 	uc.RoomManager.Rooms[roomID] = room
 	appendExpireBinarySearch(uc.RoomManager, room)
+	metrics.RoomsCreated.Inc()
 
 	// オーナーを部屋に追加
 	client := &model.Client{
 		Name:      room.Owner,
-		ClientID:  GeneratedClientID(uc.RoomManager),
-		SessionID: sessionID,
-		Ws:        nil,
+		ClientID:  clientID,
+		SessionID: session.ID,
+		Role:      model.RoleOwner,
+		JoinedAt:  time.Now(),
+		Conns:     map[string]*model.Connection{},
 	}
 
 	room.AuthenticatedClients = append(room.AuthenticatedClients, client)
@@ -81,12 +145,27 @@ func (uc *RoomUsecase) GetRoomByID(roomID string) (*model.ResponseRoom, error) {
 	if !exists {
 		return nil, errors.New("room not found")
 	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
 	res := changedForResponse(room)
 	return res, nil
 }
 
 // JoinRoom allows a client to join a room.
-func (uc *RoomUsecase) JoinRoom(roomID, clientName string) (string, error) {
+// パスワード付きの部屋では、部屋単位・IP単位の試行回数制限のうえでパスワードを照合する
+// 有効な招待トークンがあればパスワードは不要で、承認済みの招待なら承認待ちも経ずに参加できる
+// 以前の参加のセッション(クッキー・Bearerトークン)が参加禁止になっていれば、失効済みでも参加を拒否する
+// 表示名は正規化し、既存の参加者と紛らわしい場合は番号を付ける
+// 実際に使われた表示名は req.ClientName に書き戻す
+// ctx は参加ポリシーの外部への問い合わせに使い、取り消されたら参加させずにエラーを返す
+func (uc *RoomUsecase) JoinRoom(ctx context.Context, roomID string, req *model.JoinRequest) (string, error) {
+	clientName, err := validator.NormalizeDisplayName(req.ClientName)
+	if err != nil {
+		return "", err
+	}
+
 	uc.RoomManager.Mu.Lock()
 	room, exists := uc.RoomManager.Rooms[roomID]
 	uc.RoomManager.Mu.Unlock()
@@ -94,28 +173,128 @@ func (uc *RoomUsecase) JoinRoom(roomID, clientName string) (string, error) {
 	if !exists {
 		return "", errors.New("room not found")
 	}
+	ipHash := ipFingerprint(roomID, req.ClientIP)
+	target := banTarget{SessionID: req.SessionID, IPHash: ipHash, Name: clientName}
+	if req.Token != "" {
+		// キックでセッションは失効しているので、署名と部屋だけを確認してクライアントIDを取り出す
+		if claims, err := parseToken(uc.tokenSecret, req.Token, time.Now()); err == nil && claims.RoomID == roomID {
+			target.ClientID = claims.ClientID
+		}
+	}
 
-	// セッションIDの生成
-	generatedSessionID, err := GenerateSessionID()
+	room.Mu.Lock()
+	if activeBan(room, target, time.Now()) != nil {
+		room.Mu.Unlock()
+		return "", ErrBanned
+	}
+	if room.Locked {
+		room.Mu.Unlock()
+		return "", ErrRoomLocked
+	}
+	passwordHash, passwordSalt := room.PasswordHash, room.PasswordSalt
+	if req.InviteToken != "" {
+		if _, err := checkInvite(room, req.InviteToken, time.Now()); err != nil {
+			room.Mu.Unlock()
+			return "", err
+		}
+	}
+	policies := room.JoinPolicies
+	room.Mu.Unlock()
+	if passwordHash != nil && req.InviteToken == "" {
+		if err := uc.checkRoomPassword(roomID, req.ClientIP, req.Password, passwordHash, passwordSalt); err != nil {
+			return "", err
+		}
+	}
+
+	// セッションの発行
+	clientID := GeneratedClientID(uc.RoomManager)
+	session, err := issueSession(uc.RoomManager.Sessions, room, clientID)
 	if err != nil {
 		return "", err
 	}
+	generatedSessionID := session.ID
 
-	client := &model.Client{
+	// 参加ポリシーは外部に問い合わせることがあるのでロックの外で評価する
+	verdict, policyName := evaluateJoinPolicies(ctx, policies, &model.JoinCandidate{
+		RoomID:    roomID,
+		ClientID:  clientID,
 		Name:      clientName,
-		ClientID:  GeneratedClientID(uc.RoomManager),
-		SessionID: generatedSessionID,
-		Ws:        nil,
+		HasInvite: req.InviteToken != "",
+	})
+	// 承認したポリシーには、この後で実際に参加できたかどうかを知らせる
+	admitted := false
+	defer func() { settleJoinPolicies(policies, clientID, admitted) }()
+	if err := ctx.Err(); err != nil {
+		// 問い合わせ中にクライアントが切断した
+		revokeSession(uc.RoomManager.Sessions, generatedSessionID)
+		return "", err
 	}
-	fmt.Println("Client joined:", clientName)
-	fmt.Println("Client ID:", client.ClientID)
+
 	room.Mu.Lock()
 	defer room.Mu.Unlock()
-	if room.RequiresAuth {
+
+	if verdict.Outcome == model.JoinDeny {
+		revokeSession(uc.RoomManager.Sessions, generatedSessionID)
+		recordJoinDecision(room, policyName, &model.Client{ClientID: clientID, Name: clientName}, model.JoinDeny, verdict.Reason)
+		return "", fmt.Errorf("%w: %s", ErrJoinDenied, verdict.Reason)
+	}
+
+	clientName, err = validator.UniqueDisplayName(clientName, participantNames(room))
+	if err != nil {
+		revokeSession(uc.RoomManager.Sessions, generatedSessionID)
+		return "", err
+	}
+	client := &model.Client{
+		Name:      clientName,
+		ClientID:  clientID,
+		SessionID: generatedSessionID,
+		Role:      model.RoleMember,
+		IPHash:    ipHash,
+		Conns:     map[string]*model.Connection{},
+	}
+
+	preApproved := false
+	if req.InviteToken != "" {
+		// パスワード照合中に使い切られていないか、ロックを取り直して確認してから使用回数を数える
+		invite, err := checkInvite(room, req.InviteToken, time.Now())
+		if err != nil {
+			revokeSession(uc.RoomManager.Sessions, generatedSessionID)
+			return "", err
+		}
+		preApproved = invite.PreApproved
+	}
+
+	authenticated := !room.RequiresAuth || preApproved || verdict.Outcome == model.JoinApprove
+	if err := checkCanJoin(room, authenticated); err != nil {
+		revokeSession(uc.RoomManager.Sessions, generatedSessionID)
+		return "", err
+	}
+	if req.InviteToken != "" {
+		room.Invites[req.InviteToken].Uses++
+	}
+
+	if !authenticated {
 		room.UnauthenticatedClients = append(room.UnauthenticatedClients, client)
+		if verdict.DenyAfter > 0 {
+			uc.scheduleJoinTimeout(room, client, verdict.DenyAfter)
+		}
 	} else {
+		client.JoinedAt = time.Now()
 		room.AuthenticatedClients = append(room.AuthenticatedClients, client)
 	}
+	if len(policies) > 0 {
+		outcome := model.JoinPending
+		if authenticated {
+			outcome = model.JoinApprove
+		}
+		if verdict.Outcome != model.JoinApprove && preApproved {
+			// ポリシーではなく承認済みの招待で参加した
+			policyName = "invite"
+		}
+		recordJoinDecision(room, policyName, client, outcome, verdict.Reason)
+	}
+	req.ClientName = clientName
+	admitted = true
 
 	return generatedSessionID, nil
 
@@ -134,31 +313,27 @@ func (uc *RoomUsecase) Authenticate(roomID, client_id, owner_session_id string)
 	room.Mu.Lock()
 	defer room.Mu.Unlock()
 
-	fmt.Println("OwnerSessionID:", room.OwnerSessionID)
-	fmt.Println("OwnerSessionID:", owner_session_id)
-	if owner_session_id != room.OwnerSessionID {
-		return errors.New("you are not the owner of this room")
+	if _, err := authorize(room, owner_session_id, PermApprove); err != nil {
+		return err
 	}
-
-	isClientInRoom := false
-	fmt.Println("ClientID:", client_id)
-	for i, client := range room.UnauthenticatedClients {
-		if client.ClientID == client_id {
-			room.AuthenticatedClients = append(room.AuthenticatedClients, client)
-			room.UnauthenticatedClients = append(room.UnauthenticatedClients[:i], room.UnauthenticatedClients[i+1:]...)
-			isClientInRoom = true
-			break
-		}
-	}
-
-	if !isClientInRoom {
-		return errors.New("client not found in the room")
+	if err := approvePending(room, client_id); err != nil {
+		return err
 	}
-
+	notifyParticipantsChanged(room)
 	return nil
 }
 
-func (uc *RoomUsecase) UpdateRoomSettings(roomID string, newRoomSettings *model.Room, owner_session_id string) (*model.ResponseRoom, error) {
+func (uc *RoomUsecase) UpdateRoomSettings(roomID string, newRoomSettings *model.RoomSettings, owner_session_id string) (*model.ResponseRoom, error) {
+	// 新しいパスワードのハッシュ化は重いのでロックの外で行う
+	var passwordHash, passwordSalt []byte
+	if newRoomSettings.Password != nil && *newRoomSettings.Password != "" {
+		hash, salt, err := hashPassword(*newRoomSettings.Password)
+		if err != nil {
+			return nil, err
+		}
+		passwordHash, passwordSalt = hash, salt
+	}
+
 	uc.RoomManager.Mu.Lock()
 	defer uc.RoomManager.Mu.Unlock()
 
@@ -166,12 +341,65 @@ func (uc *RoomUsecase) UpdateRoomSettings(roomID string, newRoomSettings *model.
 	if !exists {
 		return nil, errors.New("room not found")
 	}
-	if room.OwnerSessionID != owner_session_id {
-		return nil, errors.New("you are not the owner of this room")
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if _, err := authorize(room, owner_session_id, PermUpdateSettings); err != nil {
+		return nil, err
 	}
 
-	room.Name = newRoomSettings.Name
-	room.RequiresAuth = newRoomSettings.RequiresAuth
+	// 一部の設定だけが変わらないよう、すべて確認してから反映する
+	roomName, err := validator.NormalizeRoomName(newRoomSettings.Name)
+	if err != nil {
+		return nil, err
+	}
+	var maxParticipants int
+	if newRoomSettings.MaxParticipants != nil {
+		maxParticipants, err = resolveMaxParticipants(*newRoomSettings.MaxParticipants, uc.Limits().MaxParticipantsCeiling)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if newRoomSettings.SlowModeSeconds != nil {
+		if err := validateSlowMode(*newRoomSettings.SlowModeSeconds); err != nil {
+			return nil, err
+		}
+	}
+	if newRoomSettings.SuccessionGraceSeconds != nil && *newRoomSettings.SuccessionGraceSeconds < 0 {
+		return nil, fmt.Errorf("%w: successionGraceSeconds must not be negative", ErrInvalidSettings)
+	}
+
+	room.Name = roomName
+	if newRoomSettings.RequiresAuth != nil {
+		room.RequiresAuth = *newRoomSettings.RequiresAuth
+	}
+	if newRoomSettings.Password != nil {
+		// 空文字の場合はパスワードを解除する
+		room.PasswordHash, room.PasswordSalt = passwordHash, passwordSalt
+	}
+	if newRoomSettings.MaxParticipants != nil {
+		// 既にいる参加者はそのままで、以降の参加・承認にだけ効く
+		room.MaxParticipants = maxParticipants
+	}
+	if newRoomSettings.Locked != nil {
+		room.Locked = *newRoomSettings.Locked
+	}
+	if newRoomSettings.SlowModeSeconds != nil {
+		room.SlowModeSeconds = *newRoomSettings.SlowModeSeconds
+	}
+	if newRoomSettings.AnnouncementOnly != nil {
+		room.AnnouncementOnly = *newRoomSettings.AnnouncementOnly
+	}
+	if newRoomSettings.AutoSuccession != nil {
+		room.AutoSuccession = *newRoomSettings.AutoSuccession
+		if !room.AutoSuccession {
+			stopSuccessionTimer(room)
+		}
+	}
+	if newRoomSettings.SuccessionGraceSeconds != nil {
+		room.SuccessionGraceSeconds = *newRoomSettings.SuccessionGraceSeconds
+	}
 
 	res := changedForResponse(room)
 
@@ -186,15 +414,69 @@ func (uc *RoomUsecase) DeleteRoom(roomID, owner_session_id string) error {
 	if !exists {
 		return errors.New("room not found")
 	}
-	if room.OwnerSessionID != owner_session_id {
-		return errors.New("you are not the owner of this room")
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if _, err := authorize(room, owner_session_id, PermDeleteRoom); err != nil {
+		return err
 	}
 
-	delete(uc.RoomManager.Rooms, roomID)
+	closeRoom(uc.RoomManager, room, "")
 	return nil
 }
 
-func (uc *RoomUsecase) KickParticipant(roomID, client_id, owner_session_id string) error {
+// CloseExpiredRoom は期限切れの部屋を閉じる(定期タスク用)
+// DeleteRoom と同じく、接続を切断してタイマーも止める
+// すでに閉じられた部屋(同じIDが別の部屋に使われている場合を含む)なら何もせずに false を返す
+// 呼び出し側で rm.Mu をロックしておくこと
+func CloseExpiredRoom(rm *model.RoomManager, room *model.Room) bool {
+	if rm.Rooms[room.ID] != room {
+		removeExpireSortRoom(rm, room)
+		return false
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	closeRoom(rm, room, "room expired")
+	return true
+}
+
+// closeRoom は部屋を削除し、セッションを失効させて残っている接続とタイマーをすべて止める
+// reason が空でなければ、閉じる前に room_closed イベントで理由を知らせる
+// 呼び出し側で rm.Mu と room.Mu をロックしておくこと
+func closeRoom(rm *model.RoomManager, room *model.Room, reason string) {
+	delete(rm.Rooms, room.ID)
+	removeExpireSortRoom(rm, room)
+	RevokeRoomSessions(rm.Sessions, room.ID)
+
+	if reason != "" {
+		event := recordEvent(room, &model.Message{
+			RoomID:    room.ID,
+			Sentence:  reason,
+			Timestamp: time.Now().Unix(),
+			Type:      "room_closed",
+		}, false)
+		if event != nil {
+			sendToAll(room, event)
+		}
+	}
+
+	// 残っている接続をすべて閉じる
+	stopSuccessionTimer(room)
+	for _, client := range room.AuthenticatedClients {
+		stopMuteTimer(client)
+		disconnectClient(client)
+	}
+	for _, client := range room.UnauthenticatedClients {
+		disconnectClient(client)
+	}
+}
+
+// KickParticipant は参加者をキックする
+// ban が nil でなければ、同時に参加禁止にする
+func (uc *RoomUsecase) KickParticipant(roomID, client_id, owner_session_id string, ban *BanOptions) error {
 	uc.RoomManager.Mu.Lock()
 	defer uc.RoomManager.Mu.Unlock()
 
@@ -202,13 +484,15 @@ func (uc *RoomUsecase) KickParticipant(roomID, client_id, owner_session_id strin
 	if !exists {
 		return errors.New("room not found")
 	}
-	if room.OwnerSessionID != owner_session_id {
-		return errors.New("you are not the owner of this room")
-	}
 
 	room.Mu.Lock()
 	defer room.Mu.Unlock()
 
+	actor, err := authorize(room, owner_session_id, PermKick)
+	if err != nil {
+		return err
+	}
+
 	if client_id == "" {
 		return errors.New("client_id is required")
 	}
@@ -216,7 +500,16 @@ func (uc *RoomUsecase) KickParticipant(roomID, client_id, owner_session_id strin
 	isClientInRoom := false
 	for i, client := range room.AuthenticatedClients {
 		if client.ClientID == client_id {
+			if !outranks(actor, client) {
+				return errors.New("you cannot kick this participant")
+			}
 			room.AuthenticatedClients = append(room.AuthenticatedClients[:i], room.AuthenticatedClients[i+1:]...)
+			if ban != nil {
+				addBan(room, client, ban, time.Now())
+			}
+			revokeSession(uc.RoomManager.Sessions, client.SessionID)
+			disconnectClient(client)
+			metrics.Kicks.Inc()
 			isClientInRoom = true
 			break
 		}
@@ -245,6 +538,8 @@ func (uc *RoomUsecase) LeaveRoom(roomID, client_session_id string) error {
 	for i, client := range room.AuthenticatedClients {
 		if client.SessionID == client_session_id {
 			room.AuthenticatedClients = append(room.AuthenticatedClients[:i], room.AuthenticatedClients[i+1:]...)
+			revokeSession(uc.RoomManager.Sessions, client.SessionID)
+			disconnectClient(client)
 			isClientInRoom = true
 			break
 		}
@@ -254,6 +549,11 @@ func (uc *RoomUsecase) LeaveRoom(roomID, client_session_id string) error {
 		return errors.New("client not found in the room")
 	}
 
+	// オーナーが退出した場合は自動引き継ぎ
+	if client_session_id == room.OwnerSessionID && room.AutoSuccession {
+		promoteSuccessor(room)
+	}
+
 	return nil
 }
 
@@ -290,18 +590,19 @@ func (uc *RoomUsecase) GetParticipants(roomID string) ([]model.Participant, []mo
 	room.Mu.Lock()
 	defer room.Mu.Unlock()
 
+	now := time.Now()
 	participants := make([]model.Participant, 0)
 	for _, client := range room.AuthenticatedClients {
 		if client.SessionID == room.OwnerSessionID {
-			participants = append(participants, model.Participant{Name: client.Name, ClientID: client.ClientID, IsOwner: true})
+			participants = append(participants, model.Participant{Name: client.Name, ClientID: client.ClientID, IsOwner: true, Role: client.Role, Online: isOnline(client), Muted: isMuted(client, now), MutedUntil: mutedUntilUnix(client)})
 		} else {
-			participants = append(participants, model.Participant{Name: client.Name, ClientID: client.ClientID, IsOwner: false})
+			participants = append(participants, model.Participant{Name: client.Name, ClientID: client.ClientID, IsOwner: false, Role: client.Role, Online: isOnline(client), Muted: isMuted(client, now), MutedUntil: mutedUntilUnix(client)})
 		}
 	}
 
 	unauthenticatedClients := make([]model.Participant, 0)
 	for _, client := range room.UnauthenticatedClients {
-		unauthenticatedClients = append(unauthenticatedClients, model.Participant{Name: client.Name, ClientID: client.ClientID, IsOwner: false})
+		unauthenticatedClients = append(unauthenticatedClients, model.Participant{Name: client.Name, ClientID: client.ClientID, IsOwner: false, Role: client.Role, Online: isOnline(client)})
 	}
 
 	return participants, unauthenticatedClients, nil
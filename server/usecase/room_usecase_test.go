@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+func TestCloseExpiredRoom(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	memberSessionID, err := uc.JoinRoom(context.Background(), room.ID, &model.JoinRequest{ClientName: "member"})
+	if err != nil {
+		t.Fatalf("JoinRoom() error = %v", err)
+	}
+	participants, _, err := uc.GetParticipants(room.ID)
+	if err != nil {
+		t.Fatalf("GetParticipants() error = %v", err)
+	}
+	if err := uc.MuteClient(room.ID, ownerSessionID, participants[1].ClientID, time.Hour); err != nil {
+		t.Fatalf("MuteClient() error = %v", err)
+	}
+	memberConn := attachConn(t, uc, room.ID, memberSessionID)
+	drainMessages(t, memberConn)
+
+	uc.RoomManager.Mu.Lock()
+	expired := uc.RoomManager.Rooms[room.ID]
+	CloseExpiredRoom(uc.RoomManager, expired)
+	uc.RoomManager.Mu.Unlock()
+
+	if _, err := uc.GetRoomByID(room.ID); err == nil {
+		t.Errorf("GetRoomByID() after CloseExpiredRoom should fail")
+	}
+	if _, err := uc.ValidateSession(room.ID, memberSessionID); err == nil {
+		t.Errorf("ValidateSession() after CloseExpiredRoom should fail")
+	}
+	if types := messageTypes(drainMessages(t, memberConn)); len(types) != 1 || types[0] != "room_closed" {
+		t.Errorf("frames = %v, want [room_closed]", types)
+	}
+
+	expired.Mu.Lock()
+	defer expired.Mu.Unlock()
+	if !memberConn.Closed {
+		t.Errorf("connection should be closed")
+	}
+	if member := findClientBySessionID(expired, memberSessionID); member.MuteTimer != nil {
+		t.Errorf("mute timer should be stopped")
+	}
+}
+
+func TestCloseExpiredRoomAfterIDReuse(t *testing.T) {
+	uc := NewRoomUsecase()
+	closed, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "closed", Owner: "owner", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	uc.RoomManager.Mu.Lock()
+	stale := uc.RoomManager.Rooms[closed.ID]
+	uc.RoomManager.Mu.Unlock()
+	if err := uc.DeleteRoom(closed.ID, ownerSessionID); err != nil {
+		t.Fatalf("DeleteRoom() error = %v", err)
+	}
+
+	uc.RoomManager.Mu.Lock()
+	defer uc.RoomManager.Mu.Unlock()
+	for _, room := range uc.RoomManager.ExpireSortRooms {
+		if room == stale {
+			t.Fatalf("DeleteRoom() should remove the room from ExpireSortRooms")
+		}
+	}
+
+	// 閉じた部屋のIDを新しい部屋が使っていても、古い部屋の期限では閉じない
+	reused := &model.Room{ID: closed.ID, Name: "reused", Expires: time.Now().Add(time.Hour)}
+	uc.RoomManager.Rooms[closed.ID] = reused
+	if CloseExpiredRoom(uc.RoomManager, stale) {
+		t.Errorf("CloseExpiredRoom() on a closed room = true, want false")
+	}
+	if uc.RoomManager.Rooms[closed.ID] != reused {
+		t.Errorf("CloseExpiredRoom() should not close the room that reused the ID")
+	}
+}
+
+func TestUpdateRoomSettingsRejectsWithoutPartialWrites(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+
+	password := "secret"
+	requiresAuth := true
+	negative := -1
+	tooMany := uc.Limits().MaxParticipantsCeiling + 1
+	tests := []*struct {
+		name     string
+		settings *model.RoomSettings
+	}{
+		{name: "invalid maxParticipants", settings: &model.RoomSettings{Name: "renamed", RequiresAuth: &requiresAuth, Password: &password, MaxParticipants: &tooMany}},
+		{name: "invalid slowModeSeconds", settings: &model.RoomSettings{Name: "renamed", RequiresAuth: &requiresAuth, Password: &password, SlowModeSeconds: &negative}},
+		{name: "invalid successionGraceSeconds", settings: &model.RoomSettings{Name: "renamed", RequiresAuth: &requiresAuth, Password: &password, SuccessionGraceSeconds: &negative}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := uc.UpdateRoomSettings(room.ID, tt.settings, ownerSessionID); err == nil {
+				t.Fatalf("UpdateRoomSettings() should fail")
+			}
+			got, err := uc.GetRoomByID(room.ID)
+			if err != nil {
+				t.Fatalf("GetRoomByID() error = %v", err)
+			}
+			if got.Name != "room" || got.RequiresAuth || got.HasPassword {
+				t.Errorf("room = {Name: %q, RequiresAuth: %v, HasPassword: %v}, want it unchanged", got.Name, got.RequiresAuth, got.HasPassword)
+			}
+		})
+	}
+}
+
+func TestUpdateRoomSettingsKeepsOmittedRequiresAuth(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", RequiresAuth: true, Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+
+	off := false
+	tests := []*struct {
+		name     string
+		settings *model.RoomSettings
+		want     bool
+	}{
+		{name: "omitted", settings: &model.RoomSettings{Name: "renamed"}, want: true},
+		{name: "turned off", settings: &model.RoomSettings{Name: "renamed", RequiresAuth: &off}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := uc.UpdateRoomSettings(room.ID, tt.settings, ownerSessionID)
+			if err != nil {
+				t.Fatalf("UpdateRoomSettings() error = %v", err)
+			}
+			if got.RequiresAuth != tt.want {
+				t.Errorf("RequiresAuth = %v, want %v", got.RequiresAuth, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"errors"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// セッションの最大有効期間(部屋の有効期限の方が早ければそちらに合わせる)
+const sessionTTL = 24 * time.Hour
+
+// NewSessionRegistry creates an empty session registry.
+func NewSessionRegistry() *model.SessionRegistry {
+	return &model.SessionRegistry{
+		Sessions: make(map[string]*model.Session),
+		Tokens:   make(map[string]string),
+	}
+}
+
+// issueSession は部屋に紐づく新しいセッションを発行してレジストリに登録する
+func issueSession(reg *model.SessionRegistry, room *model.Room, clientID string) (*model.Session, error) {
+	sessionID, err := GenerateSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(sessionTTL)
+	if !room.Expires.IsZero() && room.Expires.Before(expiresAt) {
+		expiresAt = room.Expires
+	}
+
+	session := &model.Session{
+		ID:        sessionID,
+		RoomID:    room.ID,
+		ClientID:  clientID,
+		IssuedAt:  now,
+		ExpiresAt: expiresAt,
+	}
+
+	reg.Mu.Lock()
+	reg.Sessions[sessionID] = session
+	reg.Mu.Unlock()
+
+	return session, nil
+}
+
+// validateSession はセッションが指定した部屋に対して有効かどうかを確認する
+func validateSession(reg *model.SessionRegistry, roomID, sessionID string, now time.Time) (*model.Session, error) {
+	reg.Mu.Lock()
+	defer reg.Mu.Unlock()
+
+	session, exists := reg.Sessions[sessionID]
+	if !exists {
+		return nil, errors.New("session not found")
+	}
+	if session.Revoked {
+		return nil, errors.New("session has been revoked")
+	}
+	if !now.Before(session.ExpiresAt) {
+		return nil, errors.New("session has expired")
+	}
+	if session.RoomID != roomID {
+		return nil, errors.New("session is not valid for this room")
+	}
+	return session, nil
+}
+
+// ValidateSession はセッションIDが部屋に対して有効かをレジストリで確認する
+func (uc *RoomUsecase) ValidateSession(roomID, sessionID string) (*model.Session, error) {
+	return validateSession(uc.RoomManager.Sessions, roomID, sessionID, time.Now())
+}
+
+// revokeSession はセッションを失効させる
+func revokeSession(reg *model.SessionRegistry, sessionID string) {
+	reg.Mu.Lock()
+	defer reg.Mu.Unlock()
+
+	if session, exists := reg.Sessions[sessionID]; exists {
+		session.Revoked = true
+	}
+}
+
+// RevokeRoomSessions は部屋に紐づくすべてのセッションを失効させる
+// 部屋の削除時や期限切れ時に呼ぶ
+func RevokeRoomSessions(reg *model.SessionRegistry, roomID string) {
+	reg.Mu.Lock()
+	defer reg.Mu.Unlock()
+
+	for _, session := range reg.Sessions {
+		if session.RoomID == roomID {
+			session.Revoked = true
+		}
+	}
+}
+
+// PruneSessions は失効済み・期限切れのセッションと、それに対応するトークンをレジストリから取り除く
+func PruneSessions(reg *model.SessionRegistry, now time.Time) {
+	reg.Mu.Lock()
+	defer reg.Mu.Unlock()
+
+	for id, session := range reg.Sessions {
+		if session.Revoked || !now.Before(session.ExpiresAt) {
+			delete(reg.Sessions, id)
+		}
+	}
+	for tokenID, sessionID := range reg.Tokens {
+		if _, exists := reg.Sessions[sessionID]; !exists {
+			delete(reg.Tokens, tokenID)
+		}
+	}
+}
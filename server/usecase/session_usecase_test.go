@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+func TestGenerateSessionID(t *testing.T) {
+	want := regexp.MustCompile(`^[0-9a-f]{64}$`)
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		got, err := GenerateSessionID()
+		if err != nil {
+			t.Fatalf("GenerateSessionID() error = %v", err)
+		}
+		if !want.MatchString(got) {
+			t.Errorf("GenerateSessionID() = %v, does not match %v", got, want.String())
+		}
+		if seen[got] {
+			t.Errorf("GenerateSessionID() returned duplicate %v", got)
+		}
+		seen[got] = true
+	}
+}
+
+func Test_validateSession(t *testing.T) {
+	now := time.Now()
+	reg := NewSessionRegistry()
+	reg.Sessions = map[string]*model.Session{
+		"valid":   {ID: "valid", RoomID: "ROOM1", ExpiresAt: now.Add(time.Hour)},
+		"expired": {ID: "expired", RoomID: "ROOM1", ExpiresAt: now.Add(-time.Second)},
+		"revoked": {ID: "revoked", RoomID: "ROOM1", ExpiresAt: now.Add(time.Hour), Revoked: true},
+	}
+
+	tests := []*struct {
+		name      string
+		roomID    string
+		sessionID string
+		wantErr   bool
+	}{
+		{name: "valid session", roomID: "ROOM1", sessionID: "valid", wantErr: false},
+		{name: "session for another room", roomID: "ROOM2", sessionID: "valid", wantErr: true},
+		{name: "expired session", roomID: "ROOM1", sessionID: "expired", wantErr: true},
+		{name: "revoked session", roomID: "ROOM1", sessionID: "revoked", wantErr: true},
+		{name: "unknown session", roomID: "ROOM1", sessionID: "unknown", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validateSession(reg, tt.roomID, tt.sessionID, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSession() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRevokeRoomSessions(t *testing.T) {
+	now := time.Now()
+	reg := NewSessionRegistry()
+	reg.Sessions = map[string]*model.Session{
+		"a": {ID: "a", RoomID: "ROOM1", ExpiresAt: now.Add(time.Hour)},
+		"b": {ID: "b", RoomID: "ROOM2", ExpiresAt: now.Add(time.Hour)},
+	}
+
+	RevokeRoomSessions(reg, "ROOM1")
+	if _, err := validateSession(reg, "ROOM1", "a", now); err == nil {
+		t.Errorf("session a should be revoked")
+	}
+	if _, err := validateSession(reg, "ROOM2", "b", now); err != nil {
+		t.Errorf("session b should still be valid: %v", err)
+	}
+
+	PruneSessions(reg, now)
+	if _, exists := reg.Sessions["a"]; exists {
+		t.Errorf("revoked session should be pruned")
+	}
+}
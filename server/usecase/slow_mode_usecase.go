@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/metrics"
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// ErrSlowMode はスローモードの間隔が空いていないのにメッセージを送ろうとした場合のエラー
+var ErrSlowMode = errors.New("slow mode is enabled, wait before posting again")
+
+// スローモードの間隔の上限(秒)
+const maxSlowModeSeconds = 3600
+
+// validateSlowMode はスローモードの間隔が範囲内かを確認する
+func validateSlowMode(seconds int) error {
+	if seconds < 0 || seconds > maxSlowModeSeconds {
+		return fmt.Errorf("%w: slowModeSeconds must be between 0 and %d", ErrInvalidSettings, maxSlowModeSeconds)
+	}
+	return nil
+}
+
+// checkSlowMode はスローモード中にクライアントがメッセージを送れるかを確認する
+// モデレーター以上はスローモードの対象外
+// 呼び出し側で room.Mu をロックしておくこと
+func checkSlowMode(room *model.Room, sender *model.Client, now time.Time) error {
+	if room.SlowModeSeconds <= 0 || roleRanks[sender.Role] >= roleRanks[model.RoleModerator] {
+		return nil
+	}
+	if sender.LastMessageAt.IsZero() {
+		return nil
+	}
+	next := sender.LastMessageAt.Add(time.Duration(room.SlowModeSeconds) * time.Second)
+	if now.Before(next) {
+		metrics.RateLimitHits.WithLabelValues("slow_mode").Inc()
+		return &ChatError{Code: "slow_mode", Until: next, Err: ErrSlowMode}
+	}
+	return nil
+}
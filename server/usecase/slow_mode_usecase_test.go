@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+func TestCheckSlowMode(t *testing.T) {
+	now := time.Now()
+	room := &model.Room{SlowModeSeconds: 10}
+
+	tests := []*struct {
+		name      string
+		role      model.Role
+		last      time.Time
+		wantErr   error
+		wantUntil time.Time
+	}{
+		{name: "first message", role: model.RoleMember, wantErr: nil},
+		{name: "too soon", role: model.RoleMember, last: now.Add(-3 * time.Second), wantErr: ErrSlowMode, wantUntil: now.Add(7 * time.Second)},
+		{name: "interval passed", role: model.RoleMember, last: now.Add(-10 * time.Second), wantErr: nil},
+		{name: "moderator is exempt", role: model.RoleModerator, last: now.Add(-time.Second), wantErr: nil},
+		{name: "owner is exempt", role: model.RoleOwner, last: now.Add(-time.Second), wantErr: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSlowMode(room, &model.Client{Role: tt.role, LastMessageAt: tt.last}, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkSlowMode() error = %v, want %v", err, tt.wantErr)
+			}
+			var chatErr *ChatError
+			if errors.As(err, &chatErr) && (chatErr.Code != "slow_mode" || !chatErr.Until.Equal(tt.wantUntil)) {
+				t.Errorf("checkSlowMode() = %+v, want code slow_mode until %v", chatErr, tt.wantUntil)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/logging"
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+const (
+	// SSE接続ごとの送信キューの長さ
+	sseSendBuffer = 64
+	// プロキシにアイドル切断されないように送るコメント行の間隔
+	sseHeartbeatInterval = 25 * time.Second
+)
+
+// StreamEvents はWebSocketと同じイベントをServer-Sent Eventsとして配信する
+// lastEventID が0より大きい場合は、それより後のイベントを履歴から再送してから配信を始める
+// クライアントが切断するまでブロックする
+func (uc *RoomUsecase) StreamEvents(w http.ResponseWriter, r *http.Request, roomID, sessionID, clientIP string, lastEventID int64) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("streaming is not supported")
+	}
+
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return errors.New("room not found")
+	}
+
+	connection := &model.Connection{
+		ID:          generateConnectionID(),
+		Send:        make(chan *model.Event, sseSendBuffer),
+		ConnectedAt: time.Now(),
+	}
+
+	// 再送するイベントの取得と接続の登録を同じロック内で行い、取りこぼしを防ぐ
+	room.Mu.Lock()
+	if activeBan(room, banTarget{SessionID: sessionID, IPHash: ipFingerprint(roomID, clientIP)}, time.Now()) != nil {
+		room.Mu.Unlock()
+		return ErrBanned
+	}
+	client := findClientBySessionID(room, sessionID)
+	if client == nil {
+		room.Mu.Unlock()
+		return errors.New("client not found in the room")
+	}
+	var replay []*model.Event
+	if lastEventID > 0 {
+		authenticated := isAuthenticatedClient(room, client)
+		for _, event := range room.Events {
+			if event.ID <= lastEventID {
+				continue
+			}
+			if event.AuthOnly && !authenticated {
+				continue
+			}
+			replay = append(replay, event)
+		}
+	}
+	cameOnline := !isOnline(client)
+	client.Conns[connection.ID] = connection
+	if client.SessionID == room.OwnerSessionID {
+		stopSuccessionTimer(room)
+	}
+	room.Mu.Unlock()
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // nginxのバッファリングを無効化
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	slog.Info("client connected", logging.KeyRoomID, roomID, logging.KeyClientID, client.ClientID, logging.Name(client.Name), "connection_id", connection.ID, "transport", "sse")
+
+	defer uc.closeConnection(room, client, connection)
+
+	if cameOnline {
+		uc.broadcastPresence(room, client, true)
+	}
+
+	for _, event := range replay {
+		if err := writeSSEEvent(w, event); err != nil {
+			return nil
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-connection.Send:
+			if !ok {
+				// 送信キューが詰まった等の理由でサーバー側から閉じられた
+				return nil
+			}
+			if err := writeSSEEvent(w, event); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return nil
+		}
+	}
+}
+
+// writeSSEEvent は1つのイベントをSSEの形式で書き込む
+func writeSSEEvent(w http.ResponseWriter, event *model.Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.ID, event.Data)
+	return err
+}
+
+// SendMessage はHTTP経由でチャットメッセージを送信する
+// WebSocketで "message" フレームを送った場合と同じように配信される
+func (uc *RoomUsecase) SendMessage(roomID, sessionID, content string) error {
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+
+	if !exists {
+		return errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if findClientBySessionID(room, sessionID) == nil {
+		return errors.New("client not found in the room")
+	}
+
+	return postMessage(room, sessionID, "", content)
+}
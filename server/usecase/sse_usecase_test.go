@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// readSSE はSSEのストリームから "id:" と "data:" の組を読み、メッセージとして順に送る
+func readSSE(t *testing.T, resp *http.Response, out chan<- *model.Message) {
+	defer close(out)
+	scanner := bufio.NewScanner(resp.Body)
+	var id int64
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id, _ = strconv.ParseInt(strings.TrimPrefix(line, "id: "), 10, 64)
+		case strings.HasPrefix(line, "data: "):
+			var message model.Message
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &message); err != nil {
+				t.Errorf("invalid event %q: %v", line, err)
+				return
+			}
+			if message.ID != id {
+				t.Errorf("event id = %d, data id = %d", id, message.ID)
+			}
+			out <- &message
+		}
+	}
+}
+
+func TestStreamEventsReplay(t *testing.T) {
+	tests := []*struct {
+		name    string
+		pending bool // 参加待ちのクライアントとして接続する
+		fromEnd bool // 最後のイベントのIDから再開する(false なら "before" の直後から)
+		want    []string
+	}{
+		{
+			name: "authenticated client receives later events",
+			want: []string{"message:after", "participants_update:"},
+		},
+		{
+			name:    "pending client does not receive auth only events",
+			pending: true,
+			want:    []string{"participants_update:"},
+		},
+		{
+			name:    "nothing to replay",
+			fromEnd: true,
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := NewRoomUsecase()
+			roomID, ownerSessionID, sessionIDs, _ := newGatedRoom(t, uc, "guest")
+			room := uc.RoomManager.Rooms[roomID]
+
+			if err := uc.SendMessage(roomID, ownerSessionID, "before"); err != nil {
+				t.Fatalf("SendMessage() error = %v", err)
+			}
+			room.Mu.Lock()
+			lastEventID := room.LastEventID
+			room.Mu.Unlock()
+			if err := uc.SendMessage(roomID, ownerSessionID, "after"); err != nil {
+				t.Fatalf("SendMessage() error = %v", err)
+			}
+			room.Mu.Lock()
+			notifyParticipantsChanged(room)
+			lastRecorded := room.LastEventID
+			room.Mu.Unlock()
+			if tt.fromEnd {
+				lastEventID = lastRecorded
+			}
+			sessionID := ownerSessionID
+			if tt.pending {
+				sessionID = sessionIDs[0]
+			}
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				lastEventID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+				if err := uc.StreamEvents(w, r, roomID, sessionID, "", lastEventID); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+				}
+			}))
+			defer srv.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Last-Event-ID", strconv.FormatInt(lastEventID, 10))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("GET error = %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+
+			// 再送が終わると、接続したクライアントのオンライン通知が新しいイベントとして届く
+			messages := make(chan *model.Message)
+			go readSSE(t, resp, messages)
+			var got []string
+			for message := range messages {
+				if message.ID > lastRecorded {
+					if message.Type != "presence" {
+						t.Errorf("first live event = %s, want presence", message.Type)
+					}
+					break
+				}
+				if message.ID <= lastEventID {
+					t.Errorf("replayed event %d, want only events after %d", message.ID, lastEventID)
+				}
+				got = append(got, message.Type+":"+message.Sentence)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("replayed = %v, want %v", got, tt.want)
+			}
+
+			cancel()
+			for range messages {
+			}
+		})
+	}
+}
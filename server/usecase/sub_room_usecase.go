@@ -2,11 +2,11 @@ package usecase
 
 import (
 	"fmt"
-	"time"
 
-	"crypto/sha256"
+	crand "crypto/rand"
 	"encoding/hex"
 	"math/rand/v2"
+	"time"
 
 	"github.com/takaryo1010/OneTimeChat/server/model"
 )
@@ -51,19 +51,30 @@ func appendExpireBinarySearch(rm *model.RoomManager, room *model.Room) {
 	}
 }
 
-func GenerateSessionID() (string, error) {
+// removeExpireSortRoom は期限順の一覧から部屋を取り除く
+// 閉じた部屋のIDが再利用されても、古い部屋の期限で新しい部屋が閉じられないようにする
+// 呼び出し側で rm.Mu をロックしておくこと
+func removeExpireSortRoom(rm *model.RoomManager, room *model.Room) {
+	for i, r := range rm.ExpireSortRooms {
+		if r == room {
+			rm.ExpireSortRooms = append(rm.ExpireSortRooms[:i], rm.ExpireSortRooms[i+1:]...)
+			return
+		}
+	}
+}
 
-	data := fmt.Sprintf("%d-%d", time.Now().UnixNano(), rand.Int())
-	hash := sha256.Sum256([]byte(data))
-	return hex.EncodeToString(hash[:]), nil
+// GenerateSessionID は crypto/rand による256ビットのセッションIDを生成する
+func GenerateSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
 func GeneratedClientID(roomInfo *model.RoomManager) string {
 	const chars = "ABCDEFGHJKLMNPQRSTUVWXY0123456789"
-	clientID := ""
-	for i := 0; i < 10; i++ {
-		clientID += string(chars[rand.IntN(len(chars))])
-	}
+	clientID := secureRandomString(chars, 10)
 	if _, exists := roomInfo.Rooms[clientID]; exists {
 		return GeneratedClientID(roomInfo)
 	}
@@ -72,6 +83,42 @@ func GeneratedClientID(roomInfo *model.RoomManager) string {
 
 }
 
+func generateConnectionID() string {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	return secureRandomString(chars, 12)
+}
+
+// secureRandomString は crypto/rand を使って chars からなる長さ n の文字列を生成する
+// 剰余による偏りが出ないように、charsの長さの倍数に収まらないバイトは捨てる
+func secureRandomString(chars string, n int) string {
+	limit := 256 - 256%len(chars)
+	result := make([]byte, 0, n)
+	buf := make([]byte, n)
+	for len(result) < n {
+		if _, err := crand.Read(buf); err != nil {
+			// crypto/rand の読み込みに失敗するのはOSの乱数源が使えない場合のみ
+			panic(fmt.Sprintf("crypto/rand unavailable: %v", err))
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			result = append(result, chars[int(b)%len(chars)])
+			if len(result) == n {
+				break
+			}
+		}
+	}
+	return string(result)
+}
+
+// isOnline はクライアントの接続が1つでも生きていればtrueを返す
+func isOnline(client *model.Client) bool {
+	return len(client.Conns) > 0
+}
+
+// changedForResponse は部屋をレスポンス用の形に変換する
+// 参加者の接続状態を読むので、呼び出し側で room.Mu をロックしておくこと
 func changedForResponse(room *model.Room) *model.ResponseRoom {
 	res := &model.ResponseRoom{
 		ID:           room.ID,
@@ -79,18 +126,48 @@ func changedForResponse(room *model.Room) *model.ResponseRoom {
 		Owner:        room.Owner,
 		Expires:      room.Expires,
 		RequiresAuth: room.RequiresAuth,
+		HasPassword:  room.PasswordHash != nil,
+
+		MaxParticipants:  room.MaxParticipants,
+		Locked:           room.Locked,
+		SlowModeSeconds:  room.SlowModeSeconds,
+		AnnouncementOnly: room.AnnouncementOnly,
+
+		AutoSuccession:         room.AutoSuccession,
+		SuccessionGraceSeconds: room.SuccessionGraceSeconds,
 	}
 	for _, client := range room.UnauthenticatedClients {
 		res.UnauthenticatedClients = append(res.UnauthenticatedClients, &model.ResponseClient{
 			Name:     client.Name,
 			ClientID: client.ClientID,
+			Role:     client.Role,
+			Online:   isOnline(client),
 		})
 	}
+	now := time.Now()
 	for _, client := range room.AuthenticatedClients {
 		res.AuthenticatedClients = append(res.AuthenticatedClients, &model.ResponseClient{
 			Name:     client.Name,
 			ClientID: client.ClientID,
+			Role:     client.Role,
+			Online:   isOnline(client),
+
+			Muted:      isMuted(client, now),
+			MutedUntil: mutedUntilUnix(client),
 		})
 	}
 	return res
 }
+
+// participantNames は参加待ちを含む部屋の全員の表示名を返す
+// 呼び出し側で room.Mu をロックしておくこと
+func participantNames(room *model.Room) []string {
+	names := make([]string, 0, len(room.AuthenticatedClients)+len(room.UnauthenticatedClients))
+	for _, c := range room.AuthenticatedClients {
+		names = append(names, c.Name)
+	}
+	for _, c := range room.UnauthenticatedClients {
+		names = append(names, c.Name)
+	}
+	return names
+}
@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// tokenClaims はBearerトークンに埋め込む情報
+// ロールは発行時点のもので、権限の判定には常にサーバー側の状態を使う
+type tokenClaims struct {
+	TokenID   string `json:"jti"` // セッションレジストリでセッションを引くためのID
+	RoomID    string `json:"rid"`
+	ClientID  string `json:"cid"`
+	Role      string `json:"role"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// newTokenSecret はトークン署名用の鍵を生成する
+// 部屋はメモリ上にしか存在しないため、プロセスごとに鍵を作り直しても困らない
+func newTokenSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := crand.Read(secret); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return secret
+}
+
+// IssueToken はセッションに対応するHMAC署名付きのBearerトークンを発行する
+// 形式は base64url(JSONのクレーム) + "." + base64url(HMAC-SHA256)
+func (uc *RoomUsecase) IssueToken(roomID, sessionID string) (string, error) {
+	session, err := uc.ValidateSession(roomID, sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	uc.RoomManager.Mu.Lock()
+	room, exists := uc.RoomManager.Rooms[roomID]
+	uc.RoomManager.Mu.Unlock()
+	if !exists {
+		return "", errors.New("room not found")
+	}
+
+	room.Mu.Lock()
+	client := findClientBySessionID(room, sessionID)
+	if client == nil {
+		room.Mu.Unlock()
+		return "", errors.New("client not found in the room")
+	}
+	role := string(client.Role)
+	room.Mu.Unlock()
+
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+	reg := uc.RoomManager.Sessions
+	reg.Mu.Lock()
+	reg.Tokens[tokenID] = session.ID
+	reg.Mu.Unlock()
+
+	claims := tokenClaims{
+		TokenID:   tokenID,
+		RoomID:    session.RoomID,
+		ClientID:  session.ClientID,
+		Role:      role,
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: session.ExpiresAt.Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + signToken(uc.tokenSecret, encoded), nil
+}
+
+// ResolveToken はBearerトークンを検証し、対応するセッションIDを返す
+// 署名・有効期限・部屋IDを確認したうえで、トークンIDからセッションを引いてまだ有効かを確認する
+func (uc *RoomUsecase) ResolveToken(roomID, token string) (string, error) {
+	claims, err := parseToken(uc.tokenSecret, token, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if claims.RoomID != roomID {
+		return "", errors.New("token is not valid for this room")
+	}
+
+	reg := uc.RoomManager.Sessions
+	reg.Mu.Lock()
+	sessionID, exists := reg.Tokens[claims.TokenID]
+	reg.Mu.Unlock()
+	if !exists {
+		return "", errors.New("session not found")
+	}
+
+	session, err := uc.ValidateSession(roomID, sessionID)
+	if err != nil {
+		return "", err
+	}
+	if session.ClientID != claims.ClientID {
+		return "", errors.New("token does not match the session")
+	}
+	return sessionID, nil
+}
+
+// newTokenID はトークンごとのランダムなIDを生成する
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// parseToken はトークンの署名と有効期限を検証してクレームを取り出す
+func parseToken(secret []byte, token string, now time.Time) (*tokenClaims, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, errors.New("malformed token")
+	}
+	if !hmac.Equal([]byte(signature), []byte(signToken(secret, encoded))) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("malformed token")
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New("malformed token")
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, errors.New("token has expired")
+	}
+	return &claims, nil
+}
+
+func signToken(secret []byte, encoded string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
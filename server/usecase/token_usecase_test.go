@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+func TestIssueAndResolveToken(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	token, err := uc.IssueToken(room.ID, ownerSessionID)
+	if err != nil {
+		t.Fatalf("IssueToken() error = %v", err)
+	}
+
+	tests := []*struct {
+		name    string
+		roomID  string
+		token   string
+		wantErr bool
+	}{
+		{name: "valid token", roomID: room.ID, token: token, wantErr: false},
+		{name: "token for another room", roomID: "OTHER", token: token, wantErr: true},
+		{name: "tampered token", roomID: room.ID, token: "x" + token, wantErr: true},
+		{name: "malformed token", roomID: room.ID, token: "not-a-token", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := uc.ResolveToken(tt.roomID, tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != ownerSessionID {
+				t.Errorf("ResolveToken() = %v, want %v", got, ownerSessionID)
+			}
+		})
+	}
+
+	// 部屋が削除されるとトークンも使えなくなる
+	if err := uc.DeleteRoom(room.ID, ownerSessionID); err != nil {
+		t.Fatalf("DeleteRoom() error = %v", err)
+	}
+	if _, err := uc.ResolveToken(room.ID, token); err == nil {
+		t.Errorf("ResolveToken() should fail after the room is deleted")
+	}
+
+	// 失効したセッションのトークンはセッションと一緒に取り除かれる
+	PruneSessions(uc.RoomManager.Sessions, time.Now())
+	if n := len(uc.RoomManager.Sessions.Tokens); n != 0 {
+		t.Errorf("len(Tokens) after PruneSessions() = %d, want 0", n)
+	}
+}
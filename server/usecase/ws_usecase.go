@@ -3,16 +3,23 @@ package usecase
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/takaryo1010/OneTimeChat/server/logging"
+	"github.com/takaryo1010/OneTimeChat/server/metrics"
 	"github.com/takaryo1010/OneTimeChat/server/model"
 )
 
+// 再接続(Last-Event-ID)用に部屋ごとに保持するイベント数
+const maxEventHistory = 256
+
 // HandleWebSocketConnection handles a WebSocket connection for a client.
-func (uc *RoomUsecase) HandleWebSocketConnection(w http.ResponseWriter, r *http.Request, roomID, clientName, sessionID string) error {
+// 同じクライアントが複数のタブ・端末から接続した場合は、それぞれを独立した接続として保持する
+// 送信者の名前はクエリパラメータではなく、セッションに対応するクライアントから決める
+func (uc *RoomUsecase) HandleWebSocketConnection(w http.ResponseWriter, r *http.Request, roomID, sessionID, clientIP string) error {
 	// 部屋を取得
 	uc.RoomManager.Mu.Lock()
 	room, exists := uc.RoomManager.Rooms[roomID]
@@ -22,46 +29,40 @@ func (uc *RoomUsecase) HandleWebSocketConnection(w http.ResponseWriter, r *http.
 		return errors.New("room not found")
 	}
 
-	// WebSocket 接続のアップグレード
-	conn, err := uc.upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		return err
-	}
-
 	// 部屋内に既に存在する仮のクライアントを検索
-	var client *model.Client
-	for _, c := range room.AuthenticatedClients {
-
-		if c.SessionID == sessionID {
-			client = c
-			break
-		}
-	}
-	if client == nil {
-		for _, c := range room.UnauthenticatedClients {
-
-			if c.SessionID == sessionID {
-				client = c
-				break
-			}
-
-		}
+	room.Mu.Lock()
+	if activeBan(room, banTarget{SessionID: sessionID, IPHash: ipFingerprint(roomID, clientIP)}, time.Now()) != nil {
+		room.Mu.Unlock()
+		return ErrBanned
 	}
+	client := findClientBySessionID(room, sessionID)
+	room.Mu.Unlock()
 
 	if client == nil {
 		// 仮のクライアントが見つからない場合はエラーを返す
 		return errors.New("client not found in the room")
 	}
 
-	// 仮のクライアントの WebSocket 接続を更新
-	client.Ws = conn
+	// WebSocket 接続のアップグレード
+	conn, err := uc.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return err
+	}
+
+	// クライアントの接続一覧に追加
+	connection := &model.Connection{
+		ID:          generateConnectionID(),
+		Ws:          conn,
+		ConnectedAt: time.Now(),
+	}
+	uc.addConnection(room, client, connection)
 
 	// WebSocket 接続を確立したことをログ出力
-	fmt.Printf("Client %s connected to room %s\n", clientName, roomID)
+	slog.Info("client connected", logging.KeyRoomID, roomID, logging.KeyClientID, client.ClientID, logging.Name(client.Name), "connection_id", connection.ID, "transport", "websocket")
 
 	// WebSocket のメッセージ受信ループを開始
 	go func() {
-		defer conn.Close() // 関数が終了したら接続を閉じる
+		defer uc.closeConnection(room, client, connection)
 
 		for {
 			// クライアントからメッセージを受信
@@ -71,28 +72,92 @@ func (uc *RoomUsecase) HandleWebSocketConnection(w http.ResponseWriter, r *http.
 				break
 			}
 			// 受信したメッセージを他のクライアントにブロードキャスト
-			uc.broadcastToRoom(roomID, msg, clientName, sessionID)
+			uc.broadcastToRoom(roomID, msg, sessionID, connection.ID)
 		}
 	}()
 
 	return nil
 }
 
-// broadcastToRoom broadcasts a message with sender information, room ID, and timestamp.
-func (uc *RoomUsecase) broadcastToRoom(roomID string, sentence []byte, sender, sessionID string) {
-	isClientInRoom := false
-	for _, c := range uc.RoomManager.Rooms[roomID].AuthenticatedClients {
-		if c.SessionID == sessionID {
-			isClientInRoom = true
-			break
-		}
+// addConnection はクライアントの接続一覧に接続を追加する
+// 最初の接続であればオンラインとして通知する
+func (uc *RoomUsecase) addConnection(room *model.Room, client *model.Client, connection *model.Connection) {
+	room.Mu.Lock()
+	cameOnline := !isOnline(client)
+	client.Conns[connection.ID] = connection
+	if client.SessionID == room.OwnerSessionID {
+		// オーナーが戻ってきたので自動引き継ぎを取り消す
+		stopSuccessionTimer(room)
 	}
+	room.Mu.Unlock()
 
+	if cameOnline {
+		uc.broadcastPresence(room, client, true)
+	}
+}
+
+// closeConnection は接続を閉じてクライアントの接続一覧から取り除く
+// 最後の接続が閉じられた場合のみオフラインとして通知する
+func (uc *RoomUsecase) closeConnection(room *model.Room, client *model.Client, connection *model.Connection) {
+	room.Mu.Lock()
+	closeConn(connection)
+	_, tracked := client.Conns[connection.ID]
+	delete(client.Conns, connection.ID)
+	wentOffline := tracked && !isOnline(client)
+	if wentOffline && client.SessionID == room.OwnerSessionID {
+		scheduleSuccession(room)
+	}
+	room.Mu.Unlock()
+
+	slog.Info("client disconnected", logging.KeyRoomID, room.ID, logging.KeyClientID, client.ClientID, logging.Name(client.Name), "connection_id", connection.ID)
+
+	if wentOffline {
+		uc.broadcastPresence(room, client, false)
+	}
+}
+
+// broadcastPresence はクライアントのオンライン状態の変化を部屋全体に通知する
+func (uc *RoomUsecase) broadcastPresence(room *model.Room, client *model.Client, online bool) {
+	status := "offline"
+	if online {
+		status = "online"
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	event := recordEvent(room, &model.Message{
+		RoomID:    room.ID,
+		Sentence:  status,
+		Sender:    client.Name,
+		Timestamp: time.Now().Unix(),
+		Type:      "presence",
+
+		SenderID:   client.ClientID,
+		SenderRole: client.Role,
+	}, false)
+	if event == nil {
+		return
+	}
+	sendToAll(room, event)
+}
+
+// broadcastToRoom broadcasts a message with sender information, room ID, and timestamp.
+// 送信者はセッションIDから解決する
+// connID は送信元の接続IDで、チャットメッセージはその接続以外(送信者の別タブを含む)に配信される
+func (uc *RoomUsecase) broadcastToRoom(roomID string, sentence []byte, sessionID, connID string) {
 	stringSentence := string(sentence)
 
 	type MessageType struct {
 		Type    string `json:"type"`
 		Content string `json:"content"`
+
+		// "reaction" 用(リアクション先のメッセージのID)
+		MessageID int64 `json:"message_id"`
+
+		// "mute" / "unmute" コマンド用
+		ClientID string `json:"client_id"`
+		Duration int    `json:"duration"` // ミュートする秒数(0は無期限)
 	}
 
 	var messageType MessageType
@@ -100,12 +165,7 @@ func (uc *RoomUsecase) broadcastToRoom(roomID string, sentence []byte, sender, s
 	if err != nil {
 		return
 	}
-	fmt.Println(messageType.Type)
-	fmt.Println(messageType.Content)
-
-	if !isClientInRoom && messageType.Type == "message" {
-		return
-	}
+	slog.Debug("frame received", logging.KeyRoomID, roomID, "type", messageType.Type, logging.Content(messageType.Content))
 
 	uc.RoomManager.Mu.Lock()
 	room, exists := uc.RoomManager.Rooms[roomID]
@@ -118,61 +178,260 @@ func (uc *RoomUsecase) broadcastToRoom(roomID string, sentence []byte, sender, s
 	room.Mu.Lock()
 	defer room.Mu.Unlock()
 
-	// Removed the initial assignment to message here
-	if messageType.Type == "message" {
-		// メッセージデータを作成
-		message := model.Message{
-			RoomID:    roomID,
-			Sentence:  messageType.Content,
-			Sender:    sender,
-			Timestamp: time.Now().Unix(), // 現在のUNIXタイムスタンプ
-			Type:      "message",
-		}
-		// メッセージをJSONにエンコード
-		messageJSON, err := json.Marshal(message)
-		if err != nil {
-			// エンコードエラー時の処理
-			return
-		}
+	// 接続中にキック等でいなくなっていれば何もしない
+	sender := findClientBySessionID(room, sessionID)
+	if sender == nil {
+		return
+	}
 
-		// 各クライアントにJSONメッセージを送信
-		for _, client := range room.AuthenticatedClients {
-			if client.SessionID == sessionID {
-				continue
+	switch messageType.Type {
+	case "message":
+		if err := postMessage(room, sessionID, connID, messageType.Content); err != nil {
+			var chatErr *ChatError
+			if errors.As(err, &chatErr) {
+				sendErrorFrame(room, sender, connID, chatErr.Code, chatErr.Error(), chatErr.Until)
 			}
-			err := client.Ws.WriteMessage(websocket.TextMessage, messageJSON)
-			if err != nil {
-				client.Ws.Close()
+		}
+	case "reaction":
+		if err := postReaction(room, sessionID, connID, messageType.MessageID, messageType.Content); err != nil {
+			var chatErr *ChatError
+			if errors.As(err, &chatErr) {
+				sendErrorFrame(room, sender, connID, chatErr.Code, chatErr.Error(), chatErr.Until)
 			}
 		}
-	} else if messageType.Type == "participants_update" {
+	case "mute", "unmute":
+		var err error
+		if messageType.Type == "mute" {
+			err = muteClient(room, sessionID, messageType.ClientID, time.Duration(messageType.Duration)*time.Second)
+		} else {
+			err = unmuteClient(room, sessionID, messageType.ClientID)
+		}
+		if err != nil {
+			sendErrorFrame(room, sender, connID, "command_failed", err.Error(), time.Time{})
+		}
+	case "participants_update":
 		// メッセージデータを作成
-		message := model.Message{
+		event := recordEvent(room, &model.Message{
 			RoomID:    roomID,
 			Sentence:  stringSentence,
-			Sender:    sender,
+			Sender:    sender.Name,
 			Timestamp: time.Now().Unix(), // 現在のUNIXタイムスタンプ
 			Type:      "participants_update",
-		}
-		// メッセージをJSONにエンコード
-		messageJSON, err := json.Marshal(message)
-		if err != nil {
+
+			SenderID:   sender.ClientID,
+			SenderRole: sender.Role,
+		}, false)
+		if event == nil {
 			// エンコードエラー時の処理
 			return
 		}
 
-		for _, client := range room.AuthenticatedClients {
-			err := client.Ws.WriteMessage(websocket.TextMessage, messageJSON)
-			if err != nil {
-				client.Ws.Close()
-			}
+		sendToAll(room, event)
+	}
+}
+
+// ChatError はチャットメッセージを送れなかった理由を表すエラー
+// WebSocketでは Type "error" のフレームとして送信元の接続に返される
+type ChatError struct {
+	Code  string    // エラーの種類 ("muted" など)
+	Until time.Time // 再び送信できるようになる時刻(不明ならゼロ値)
+	Err   error
+}
+
+func (e *ChatError) Error() string { return e.Err.Error() }
+func (e *ChatError) Unwrap() error { return e.Err }
+
+// sendErrorFrame は送信元の接続にだけエラーを知らせるフレームを送る
+// 呼び出し側で room.Mu をロックしておくこと
+func sendErrorFrame(room *model.Room, client *model.Client, connID, code, text string, until time.Time) {
+	conn, exists := client.Conns[connID]
+	if !exists {
+		return
+	}
+	message := &model.Message{
+		RoomID:    room.ID,
+		Sentence:  text,
+		Timestamp: time.Now().Unix(),
+		Type:      "error",
+		Code:      code,
+	}
+	if !until.IsZero() {
+		message.Until = until.Unix()
+	}
+	if event := directEvent(room, message); event != nil {
+		sendToConn(conn, event)
+	}
+}
+
+// postMessage はチャットメッセージを認証済みクライアントに配信する
+// WebSocketとHTTP(POST /room/:id/messages)の両方から呼ばれる
+// 呼び出し側で room.Mu をロックしておくこと
+func postMessage(room *model.Room, sessionID, connID, content string) error {
+	sender, err := authorize(room, sessionID, PermSendMessage)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if isMuted(sender, now) {
+		return &ChatError{Code: "muted", Until: sender.MutedUntil, Err: ErrMuted}
+	}
+	if err := checkAnnouncementOnly(room, sender); err != nil {
+		return err
+	}
+	if err := checkSlowMode(room, sender, now); err != nil {
+		return err
+	}
+
+	// メッセージデータを作成
+	event := recordEvent(room, &model.Message{
+		RoomID:    room.ID,
+		Sentence:  content,
+		Sender:    sender.Name,
+		Timestamp: time.Now().Unix(), // 現在のUNIXタイムスタンプ
+		Type:      "message",
+
+		SenderID:   sender.ClientID,
+		SenderRole: sender.Role,
+	}, true)
+	if event == nil {
+		return errors.New("failed to encode message")
+	}
+	sender.LastMessageAt = now
+
+	// 各クライアントにJSONメッセージを送信
+	start := time.Now()
+	for _, client := range room.AuthenticatedClients {
+		sendToClientExcept(client, event, connID)
+	}
+	metrics.BroadcastFanout.Observe(time.Since(start).Seconds())
+	metrics.MessagesBroadcast.Inc()
+	return nil
+}
+
+// recordEvent はメッセージにイベントIDを振ってJSONにエンコードし、再送用の履歴に追加する
+// authOnly が true のイベントは再送時も認証済みクライアントにのみ送られる
+// 呼び出し側で room.Mu をロックしておくこと
+func recordEvent(room *model.Room, message *model.Message, authOnly bool) *model.Event {
+	message.ID = room.LastEventID + 1
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil
+	}
+	room.LastEventID = message.ID
+
+	event := &model.Event{ID: message.ID, Data: data, AuthOnly: authOnly}
+	room.Events = append(room.Events, event)
+	if len(room.Events) > maxEventHistory {
+		room.Events = room.Events[len(room.Events)-maxEventHistory:]
+	}
+	return event
+}
+
+// directEvent は特定のクライアントだけに送るフレームを作る
+// 履歴には残さず、IDは現在の位置のままにする(SSEの再開位置を進めない)
+// 呼び出し側で room.Mu をロックしておくこと
+func directEvent(room *model.Room, message *model.Message) *model.Event {
+	message.ID = room.LastEventID
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil
+	}
+	return &model.Event{ID: message.ID, Data: data}
+}
+
+// sendToAll は認証済み・未認証の全クライアントにイベントを送信する
+// 呼び出し側で room.Mu をロックしておくこと
+func sendToAll(room *model.Room, event *model.Event) {
+	for _, client := range room.AuthenticatedClients {
+		sendToClient(client, event)
+	}
+	for _, client := range room.UnauthenticatedClients {
+		sendToClient(client, event)
+	}
+}
+
+// sendToClient はクライアントが持つすべての接続にイベントを送信する
+// 書き込みに失敗した接続は閉じ、受信ループ側の後処理で接続一覧から取り除かれる
+// 呼び出し側で room.Mu をロックしておくこと
+func sendToClient(client *model.Client, event *model.Event) {
+	sendToClientExcept(client, event, "")
+}
+
+// sendToClientExcept は exceptConnID の接続を除いたすべての接続にイベントを送信する
+func sendToClientExcept(client *model.Client, event *model.Event, exceptConnID string) {
+	for id, conn := range client.Conns {
+		if id == exceptConnID {
+			continue
 		}
-		for _, client := range room.UnauthenticatedClients {
-			err := client.Ws.WriteMessage(websocket.TextMessage, messageJSON)
-			if err != nil {
-				client.Ws.Close()
-			}
+		sendToConn(conn, event)
+	}
+}
+
+// sendToConn は1つの接続にイベントを送信する
+// SSE接続の送信キューが詰まっている場合は接続を閉じ、クライアントに Last-Event-ID で再接続させる
+func sendToConn(conn *model.Connection, event *model.Event) {
+	if conn.Closed {
+		return
+	}
+	if conn.Ws != nil {
+		err := conn.Ws.WriteMessage(websocket.TextMessage, event.Data)
+		if err != nil {
+			closeConn(conn)
+		}
+		return
+	}
+	select {
+	case conn.Send <- event:
+	default:
+		closeConn(conn)
+	}
+}
+
+// closeConn は接続を閉じる(複数回呼んでもよい)
+// 呼び出し側で room.Mu をロックしておくこと
+func closeConn(conn *model.Connection) {
+	if conn.Closed {
+		return
+	}
+	conn.Closed = true
+	if conn.Ws != nil {
+		conn.Ws.Close()
+	} else {
+		close(conn.Send)
+	}
+}
+
+// disconnectClient はクライアントのすべての接続を閉じる
+// 呼び出し側で room.Mu をロックしておくこと
+func disconnectClient(client *model.Client) {
+	for _, conn := range client.Conns {
+		closeConn(conn)
+	}
+}
+
+// findClientBySessionID は認証済み・未認証の両方からセッションIDに一致するクライアントを探す
+// 呼び出し側で room.Mu をロックしておくこと
+func findClientBySessionID(room *model.Room, sessionID string) *model.Client {
+	for _, c := range room.AuthenticatedClients {
+		if c.SessionID == sessionID {
+			return c
 		}
+	}
+	for _, c := range room.UnauthenticatedClients {
+		if c.SessionID == sessionID {
+			return c
+		}
+	}
+	return nil
+}
 
+// isAuthenticatedClient はクライアントが認証済みかどうかを返す
+// 呼び出し側で room.Mu をロックしておくこと
+func isAuthenticatedClient(room *model.Room, client *model.Client) bool {
+	for _, c := range room.AuthenticatedClients {
+		if c == client {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/takaryo1010/OneTimeChat/server/model"
+)
+
+// presenceOf はフレームのうち sender のオンライン状態の通知("online" / "offline")を順に返す
+func presenceOf(messages []*model.Message, sender string) []string {
+	var statuses []string
+	for _, m := range messages {
+		if m.Type == "presence" && m.Sender == sender {
+			statuses = append(statuses, m.Sentence)
+		}
+	}
+	return statuses
+}
+
+func TestMultipleConnectionsPerSession(t *testing.T) {
+	uc := NewRoomUsecase()
+	roomID, ownerSessionID, sessionIDs, _ := newOwnedRoom(t, uc, &model.Room{}, "alice")
+	ownerConn := attachConn(t, uc, roomID, ownerSessionID)
+
+	// 同じセッションで2つのタブから接続する
+	first := attachConn(t, uc, roomID, sessionIDs[0])
+	second := attachConn(t, uc, roomID, sessionIDs[0])
+	if got := presenceOf(drainMessages(t, ownerConn), "alice"); len(got) != 1 || got[0] != "online" {
+		t.Errorf("presence after two connections = %v, want [online]", got)
+	}
+
+	if err := uc.SendMessage(roomID, ownerSessionID, "hello"); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	for i, conn := range []*model.Connection{first, second} {
+		if messages := drainMessages(t, conn); !hasType(messages, "message") {
+			t.Errorf("connection %d frames = %v, want the broadcast message", i, messageTypes(messages))
+		}
+	}
+
+	tests := []*struct {
+		name         string
+		conn         *model.Connection
+		wantOnline   bool
+		wantPresence []string
+	}{
+		{name: "closing one connection keeps the client online", conn: first, wantOnline: true, wantPresence: nil},
+		{name: "closing the last connection goes offline", conn: second, wantOnline: false, wantPresence: []string{"offline"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detachConn(t, uc, roomID, sessionIDs[0], tt.conn)
+
+			participants, _, err := uc.GetParticipants(roomID)
+			if err != nil {
+				t.Fatalf("GetParticipants() error = %v", err)
+			}
+			if participants[1].Online != tt.wantOnline {
+				t.Errorf("Online = %v, want %v", participants[1].Online, tt.wantOnline)
+			}
+			got := presenceOf(drainMessages(t, ownerConn), "alice")
+			if len(got) != len(tt.wantPresence) || (len(got) > 0 && got[0] != tt.wantPresence[0]) {
+				t.Errorf("presence = %v, want %v", got, tt.wantPresence)
+			}
+		})
+	}
+}
+
+func TestGetRoomByIDWhileConnecting(t *testing.T) {
+	uc := NewRoomUsecase()
+	room, ownerSessionID, err := uc.CreateRoom(&model.Room{Name: "room", Owner: "owner", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+
+	uc.RoomManager.Mu.Lock()
+	target := uc.RoomManager.Rooms[room.ID]
+	uc.RoomManager.Mu.Unlock()
+	target.Mu.Lock()
+	owner := findClientBySessionID(target, ownerSessionID)
+	target.Mu.Unlock()
+
+	// 接続の追加・削除と並行して部屋の情報を読んでもデータ競合にならない(go test -race で確認する)
+	// 実際の接続と同じく、接続の追加・削除では rm.Mu をロックしない
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			conn := &model.Connection{ID: generateConnectionID(), Send: make(chan *model.Event, 64)}
+			uc.addConnection(target, owner, conn)
+			uc.closeConnection(target, owner, conn)
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if _, err := uc.GetRoomByID(room.ID); err != nil {
+			t.Fatalf("GetRoomByID() error = %v", err)
+		}
+	}
+	<-done
+}
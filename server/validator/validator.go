@@ -1 +1,148 @@
 package validator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// ErrNameTaken は紛らわしくない名前を用意できなかった場合のエラー
+	ErrNameTaken = errors.New("name is already taken in this room")
+	// ErrInvalidName は名前が使える文字・長さの規則に合わない場合のエラー
+	ErrInvalidName = errors.New("invalid name")
+)
+
+const (
+	// MaxDisplayNameLength は表示名(参加者名・オーナー名)の最大文字数
+	MaxDisplayNameLength = 32
+	// MaxRoomNameLength は部屋名の最大文字数
+	MaxRoomNameLength = 64
+)
+
+// NormalizeDisplayName は表示名を NFC に正規化し、使える文字と長さを確認する
+// 前後の空白は取り除き、連続する空白は1つにまとめる
+func NormalizeDisplayName(name string) (string, error) {
+	return normalize("name", name, MaxDisplayNameLength)
+}
+
+// NormalizeRoomName は部屋名を表示名と同じ規則で正規化する
+func NormalizeRoomName(name string) (string, error) {
+	return normalize("room name", name, MaxRoomNameLength)
+}
+
+func normalize(field, s string, maxLength int) (string, error) {
+	if !utf8.ValidString(s) {
+		return "", fmt.Errorf("%w: %s must be valid UTF-8", ErrInvalidName, field)
+	}
+	s = norm.NFC.String(s)
+
+	var b strings.Builder
+	space := false
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case unicode.IsSpace(r):
+			// 改行やタブも含めて半角スペース1つにする
+			space = true
+			continue
+		case !allowedRune(r):
+			return "", fmt.Errorf("%w: %s contains a character that is not allowed: %U", ErrInvalidName, field, r)
+		}
+		if space {
+			b.WriteRune(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	s = b.String()
+
+	if s == "" {
+		return "", fmt.Errorf("%w: %s must not be empty", ErrInvalidName, field)
+	}
+	if n := utf8.RuneCountInString(s); n > maxLength {
+		return "", fmt.Errorf("%w: %s must be at most %d characters", ErrInvalidName, field, maxLength)
+	}
+	return s, nil
+}
+
+// allowedRune は表示名に使える文字かを返す
+// 制御文字・書式文字(ゼロ幅文字や双方向制御文字)・私用領域・未割り当ての文字は使えない
+func allowedRune(r rune) bool {
+	if r == utf8.RuneError {
+		return false
+	}
+	return unicode.In(r, unicode.L, unicode.M, unicode.N, unicode.P, unicode.S, unicode.Zs)
+}
+
+// confusables は見た目がラテン文字と紛らわしい文字の対応表
+// 表示名のなりすましでよく使われるキリル文字・ギリシャ文字と、数字・記号を扱う
+// 大文字小文字を統一した後に引くので、"I"(小文字の "i" になる)・"l"・"1" のような縦棒に見える文字はすべて "l" にまとめる
+var confusables = map[rune]rune{
+	// キリル文字
+	'а': 'a', 'в': 'b', 'с': 'c', 'ԁ': 'd', 'е': 'e', 'һ': 'h', 'і': 'l', 'ј': 'j',
+	'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'ԛ': 'q', 'ѕ': 's', 'т': 't',
+	'у': 'y', 'ѡ': 'w', 'х': 'x', 'ү': 'y', 'ӏ': 'l',
+	// ギリシャ文字
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'l', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+	// 数字・記号
+	'0': 'o', '1': 'l', '3': 'e', '5': 's', '|': 'l', '!': 'l', 'ı': 'l',
+	'ǀ': 'l', 'i': 'l',
+}
+
+// Skeleton は紛らわしい表示名を比較するための文字列を返す
+// NFKC 正規化・大文字小文字の統一・結合文字の除去・紛らわしい文字の置き換えを行い、空白と区切り記号は無視する
+func Skeleton(name string) string {
+	s := cases.Fold().String(norm.NFKC.String(name))
+	s = norm.NFD.String(s)
+
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.Is(unicode.Mn, r) || unicode.IsSpace(r) || r == '_' || r == '-' || r == '.' {
+			continue
+		}
+		if c, ok := confusables[r]; ok {
+			r = c
+		}
+		b.WriteRune(r)
+	}
+	// rn と m のように複数文字で紛らわしいものは置き換え後にまとめて扱う
+	return strings.ReplaceAll(b.String(), "rn", "m")
+}
+
+// Confusable は2つの表示名が見た目で区別しにくいかを返す
+func Confusable(a, b string) bool {
+	return Skeleton(a) == Skeleton(b)
+}
+
+// maxSuffix は重複した表示名に付ける番号の上限
+const maxSuffix = 99
+
+// UniqueDisplayName は既存の名前と紛らわしくないように、必要なら " 2" のような番号を付けた名前を返す
+func UniqueDisplayName(name string, existing []string) (string, error) {
+	taken := make(map[string]bool, len(existing))
+	for _, e := range existing {
+		taken[Skeleton(e)] = true
+	}
+	if !taken[Skeleton(name)] {
+		return name, nil
+	}
+	for i := 2; i <= maxSuffix; i++ {
+		suffix := fmt.Sprintf(" %d", i)
+		base := name
+		// 番号を付けても最大文字数に収まるように元の名前を切り詰める
+		if runes := []rune(base); len(runes)+len(suffix) > MaxDisplayNameLength {
+			base = strings.TrimSpace(string(runes[:MaxDisplayNameLength-len(suffix)]))
+		}
+		candidate := base + suffix
+		if !taken[Skeleton(candidate)] {
+			return candidate, nil
+		}
+	}
+	return "", ErrNameTaken
+}
@@ -0,0 +1,91 @@
+package validator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeDisplayName(t *testing.T) {
+	tests := []*struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", input: "alice", want: "alice"},
+		{name: "trim and collapse spaces", input: "  alice \t bob  ", want: "alice bob"},
+		{name: "NFC", input: "e\u0301", want: "é"},
+		{name: "japanese", input: "たろう", want: "たろう"},
+		{name: "empty", input: "   ", wantErr: true},
+		{name: "too long", input: strings.Repeat("a", MaxDisplayNameLength+1), wantErr: true},
+		{name: "control character", input: "ali\x07ce", wantErr: true},
+		{name: "zero width space", input: "ali\u200bce", wantErr: true},
+		{name: "bidi override", input: "\u202eecila", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeDisplayName(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeDisplayName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidName) {
+				t.Errorf("NormalizeDisplayName() error = %v, want %v", err, ErrInvalidName)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeDisplayName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUniqueDisplayName(t *testing.T) {
+	existing := []string{"Owner", "alice", "alice 2"}
+	tests := []*struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "unique", input: "bob", want: "bob"},
+		{name: "exact duplicate", input: "alice", want: "alice 3"},
+		{name: "case only", input: "OWNER", want: "OWNER 2"},
+		{name: "cyrillic lookalike", input: "оwnеr", want: "оwnеr 2"},
+		{name: "digit lookalike", input: "0wner", want: "0wner 2"},
+		{name: "accent only", input: "ówner", want: "ówner 2"},
+		{name: "capital I for l", input: "AIice", want: "AIice 3"},
+		{name: "digit one for l", input: "a1ice", want: "a1ice 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UniqueDisplayName(tt.input, existing)
+			if err != nil {
+				t.Fatalf("UniqueDisplayName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("UniqueDisplayName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfusable(t *testing.T) {
+	tests := []*struct {
+		a, b string
+		want bool
+	}{
+		{a: "Alice", b: "AIice", want: true},
+		{a: "Alice", b: "A1ice", want: true},
+		{a: "Alice", b: "Alıce", want: true},
+		{a: "Alice", b: "Аlice", want: true},
+		{a: "Alice", b: "Alike", want: false},
+		{a: "modern", b: "modem", want: true},
+		{a: "Alice", b: "Bob", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := Confusable(tt.a, tt.b); got != tt.want {
+				t.Errorf("Confusable(%q, %q) = %v, want %v (skeletons %q, %q)", tt.a, tt.b, got, tt.want, Skeleton(tt.a), Skeleton(tt.b))
+			}
+		})
+	}
+}